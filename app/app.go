@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/zander-84/gull/registry"
 	"github.com/zander-84/gull/transport"
+	"log"
 	"os"
 	"os/signal"
 	"sort"
@@ -46,6 +47,7 @@ func New(opts ...Option) *App {
 		afterStopEvents:   make(map[int][]Event, 0),
 		finalEvents:       make(map[int][]Event, 0),
 		eventsTimeOut:     time.Minute,
+		upgradeTimeout:    time.Minute,
 	}
 	if id, err := uuid.NewUUID(); err == nil {
 		o.id = id.String()
@@ -111,6 +113,13 @@ func (a *App) Run() error {
 		})
 	}
 	wg.Wait()
+	if !waitReady(ctx, a.opts.servers) {
+		// a server failed to start, eg.Wait returns its error
+		err = eg.Wait()
+		a.afterStop()
+		a.finalStop()
+		return err
+	}
 	if a.opts.registrar != nil {
 		rctx, rcancel := context.WithTimeout(ctx, a.opts.registrarTimeout)
 		defer rcancel()
@@ -120,16 +129,30 @@ func (a *App) Run() error {
 	}
 
 	a.afterStart()
+	notifyReady()
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, a.opts.sigs...)
+	up := make(chan os.Signal, 1)
+	if a.opts.upgradeSig != nil {
+		signal.Notify(up, a.opts.upgradeSig)
+	}
 	eg.Go(func() error {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-c:
-			a.beforeStop()
-			return a.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-up:
+				if err := a.upgrade(); err != nil {
+					log.Printf("[APP] upgrade: %v", err)
+					continue
+				}
+				a.beforeStop()
+				return a.Stop()
+			case <-c:
+				a.beforeStop()
+				return a.Stop()
+			}
 		}
 	})
 
@@ -183,6 +206,23 @@ func (a *App) buildInstance() (*registry.ServiceInstance, error) {
 	}, nil
 }
 
+// waitReady waits for the servers telling when they accept connections, it
+// is false when ctx is done first, as when one of them failed to start.
+func waitReady(ctx context.Context, servers []transport.Server) bool {
+	for _, srv := range servers {
+		r, ok := srv.(transport.Readier)
+		if !ok {
+			continue
+		}
+		select {
+		case <-r.Ready():
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
 type appKey struct{}
 
 // NewContext returns a new Context that carries value.
@@ -11,6 +11,7 @@ import (
 	"github.com/zander-84/gull/pbs"
 	"github.com/zander-84/gull/registry"
 	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
 	"github.com/zander-84/gull/transport/grpc"
 	"github.com/zander-84/gull/transport/http"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -58,7 +59,7 @@ func TestApp(t *testing.T) {
 		endpoint.Grpc: func(ctx context.Context, err error) {
 
 		},
-	}))).Use(endpoint.OptionsRecoverEncoder(endpoint.WrapRecover(map[endpoint.Protocol]func(ctx context.Context){
+	}))).Use(endpoint.OptionsRecoverEncoder(endpoint.WrapRecover(map[endpoint.Protocol]func(ctx context.Context, rec interface{}) error{
 		endpoint.Http: think.Recover,
 		endpoint.Grpc: think.Recover,
 	})))
@@ -175,7 +176,7 @@ func TestApp2(t *testing.T) {
 		endpoint.Grpc: func(ctx context.Context, err error) {
 
 		},
-	}))).Use(endpoint.OptionsRecoverEncoder(endpoint.WrapRecover(map[endpoint.Protocol]func(ctx context.Context){
+	}))).Use(endpoint.OptionsRecoverEncoder(endpoint.WrapRecover(map[endpoint.Protocol]func(ctx context.Context, rec interface{}) error{
 		endpoint.Http: think.Recover,
 		endpoint.Grpc: think.Recover,
 	})))
@@ -502,3 +503,21 @@ func TestApp_Context(t *testing.T) {
 		})
 	}
 }
+
+func TestWaitReady(t *testing.T) {
+	hs := http.NewServer("127.0.0.1:0")
+	go func() { _ = hs.Start(context.Background()) }()
+	defer hs.Stop(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if !waitReady(ctx, []transport.Server{hs}) {
+		t.Fatal("a started server should be ready")
+	}
+
+	// one that never accepts, like a server failing to start
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if waitReady(ctx, []transport.Server{hs, http.NewServer("127.0.0.1:0")}) {
+		t.Fatal("a server not accepting should not be ready")
+	}
+}
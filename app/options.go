@@ -23,6 +23,9 @@ type options struct {
 	ctx  context.Context
 	sigs []os.Signal
 
+	upgradeSig     os.Signal
+	upgradeTimeout time.Duration
+
 	registrar        registry.Registrar
 	registrarTimeout time.Duration
 	stopTimeout      time.Duration
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zander-84/gull/internal/listen"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// envReady holds the fd of the pipe a process started by an upgrade writes
+// to once it serves.
+const envReady = "GULL_UPGRADE_READY"
+
+// Upgrade makes sig, like syscall.SIGUSR2, restart the application without
+// downtime: the binary, maybe replaced on disk, is started again with the
+// listeners of the servers, and the application stops once the new process
+// serves, within timeout, a minute by default. The application keeps running
+// when it does not.
+func Upgrade(sig os.Signal, timeout time.Duration) Option {
+	return func(o *options) {
+		o.upgradeSig = sig
+		if timeout > 0 {
+			o.upgradeTimeout = timeout
+		}
+	}
+}
+
+// upgrade starts the new process and waits for it to serve.
+func (a *App) upgrade() error {
+	names, files, err := listen.Files()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(),
+		listen.EnvUpgrade+"="+names,
+		envReady+"="+strconv.Itoa(3+len(files)),
+	)
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if n, _ := r.Read(b); n == 1 {
+			ready <- nil
+			return
+		}
+		ready <- errors.New("the new process exited before serving")
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(a.opts.upgradeTimeout):
+		err = fmt.Errorf("the new process did not serve within %s", a.opts.upgradeTimeout)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+	listen.HandOver()
+	log.Printf("[APP] upgraded to pid %d", cmd.Process.Pid)
+	return nil
+}
+
+// notifyReady tells the parent of an upgrade that the servers are serving.
+func notifyReady() {
+	fd, err := strconv.Atoi(os.Getenv(envReady))
+	if err != nil {
+		return
+	}
+	_ = os.Unsetenv(envReady)
+	_ = os.Unsetenv(listen.EnvUpgrade)
+	f := os.NewFile(uintptr(fd), envReady)
+	_, _ = f.Write([]byte{1})
+	_ = f.Close()
+}
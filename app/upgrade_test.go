@@ -0,0 +1,74 @@
+//go:build unix
+
+package app
+
+import (
+	"github.com/zander-84/gull/transport/http"
+	"io"
+	http2 "net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUpgrade(t *testing.T) {
+	child := os.Getenv(envReady) != ""
+	hs := http.NewServer("127.0.0.1:0", http.ServerRouter())
+	a := New(Server(hs), Upgrade(syscall.SIGUSR2, 10*time.Second))
+	hs.Router().HandleFunc(http2.MethodGet, "/pid", func(w http2.ResponseWriter, req *http2.Request) {
+		if child {
+			_, _ = w.Write([]byte("child"))
+			go a.Stop()
+			return
+		}
+		_, _ = w.Write([]byte("parent"))
+	})
+	if child {
+		if err := a.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	u, err := hs.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() string {
+		res, err := http2.Get(u.String() + "/pid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return string(b)
+	}
+	done := make(chan error, 1)
+	go func() { done <- a.Run() }()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if res, err := http2.Get(u.String() + "/pid"); err == nil {
+			res.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the parent does not serve")
+		}
+	}
+	// the new process runs this test only, as the child
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgrade$"}
+	defer func() { os.Args = args }()
+	_ = syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the parent did not stop after the upgrade")
+	}
+	if got := get(); got != "child" {
+		t.Fatalf("served by the %s after the upgrade", got)
+	}
+}
@@ -2,12 +2,29 @@ package lb
 
 import (
 	"errors"
+	"fmt"
 )
 
 // ErrNoNode is returned when no qualifying node are available.
 var ErrNoNode = errors.New("no node available")
 var ErrNotImplemented = errors.New("not implemented")
 
+// NodeError reports the health of a single node through Listener.RecordErr.
+// A non nil Err takes the node out of rotation, a nil Err puts it back.
+type NodeError struct {
+	Node any
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("node %v recovered", e.Node)
+	}
+	return fmt.Sprintf("node %v: %v", e.Node, e.Err)
+}
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
 type Listener interface {
 	CleanErr()
 	RecordErr(err error)
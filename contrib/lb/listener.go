@@ -2,6 +2,7 @@ package lb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -12,6 +13,7 @@ var _ Listener = (*DynamicData)(nil)
 type DynamicData struct {
 	name       string
 	data       map[any]int
+	tripped    map[any]error
 	version    uint64
 	lock       sync.RWMutex
 	ctx        context.Context
@@ -25,6 +27,7 @@ func NewListener(name string) *DynamicData {
 	dd := new(DynamicData)
 	dd.version = 0
 	dd.data = make(map[any]int)
+	dd.tripped = make(map[any]error)
 	dd.name = name
 
 	dd.ctx, dd.cancelFunc = context.WithCancel(context.Background())
@@ -40,6 +43,7 @@ func (dd *DynamicData) Close() {
 	dd.cancelFunc()
 	atomic.StoreUint64(&dd.version, 0)
 	dd.data = make(map[any]int)
+	dd.tripped = make(map[any]error)
 
 }
 
@@ -98,6 +102,9 @@ func (dd *DynamicData) Get() (map[any]int, []any, uint64) {
 	var dataMap = make(map[any]int, 0)
 	var dataSlice = make([]any, 0)
 	for k, v := range dd.data {
+		if _, ok := dd.tripped[k]; ok {
+			continue
+		}
 		dataMap[k] = v
 		dataSlice = append(dataSlice, k)
 	}
@@ -105,12 +112,39 @@ func (dd *DynamicData) Get() (map[any]int, []any, uint64) {
 	return dataMap, dataSlice, atomic.LoadUint64(&dd.version)
 }
 
+// RecordErr 记录错误, *NodeError 只摘除或恢复单个节点
 func (dd *DynamicData) RecordErr(err error) {
+	var ne *NodeError
+	if errors.As(err, &ne) {
+		dd.recordNodeErr(ne)
+		return
+	}
 	dd.errLock.Lock()
 	defer dd.errLock.Unlock()
 	dd.err = err
 }
 
+func (dd *DynamicData) recordNodeErr(ne *NodeError) {
+	dd.lock.Lock()
+	defer dd.lock.Unlock()
+	_, ok := dd.tripped[ne.Node]
+	if ne.Err != nil {
+		dd.tripped[ne.Node] = ne.Err
+	} else {
+		delete(dd.tripped, ne.Node)
+	}
+	if ok != (ne.Err != nil) {
+		atomic.AddUint64(&dd.version, 1)
+	}
+}
+
+// NodeErr returns the error a node was taken out of rotation with.
+func (dd *DynamicData) NodeErr(addr any) error {
+	dd.lock.RLock()
+	defer dd.lock.RUnlock()
+	return dd.tripped[addr]
+}
+
 func (dd *DynamicData) CleanErr() {
 	dd.errLock.Lock()
 	defer dd.errLock.Unlock()
@@ -5,9 +5,19 @@ import (
 	"github.com/zander-84/gull/tool"
 )
 
+const (
+	// PrincipalKey is the CtxVal key holding the authenticated caller.
+	PrincipalKey = "principal"
+	// RequestIDKey is the CtxVal key holding the request id.
+	RequestIDKey = "request_id"
+)
+
 type CtxVal struct {
 	data     *tool.ConcurrentMap
 	protocol Protocol
+	method   Method
+	path     string
+	values   map[string]interface{}
 }
 
 type endpointKey struct{}
@@ -20,6 +30,12 @@ func MustGetCtxVal(ctx context.Context) *CtxVal {
 	return v
 }
 
+// GetCtxVal returns the CtxVal stored in ctx, if any.
+func GetCtxVal(ctx context.Context) (*CtxVal, bool) {
+	v, ok := ctx.Value(endpointKey{}).(*CtxVal)
+	return v, ok
+}
+
 func NewCtxVal() *CtxVal {
 	ctx := new(CtxVal)
 	ctx.data = tool.NewConcurrentMap()
@@ -40,3 +56,44 @@ func (ctx *CtxVal) SetProtocol(protocol Protocol) {
 func (ctx *CtxVal) GetProtocol() Protocol {
 	return ctx.protocol
 }
+
+// SetRoute records the registered method and path of the running endpoint.
+func (ctx *CtxVal) SetRoute(method Method, path string) {
+	ctx.method = method
+	ctx.path = path
+}
+
+func (ctx *CtxVal) GetMethod() Method {
+	return ctx.method
+}
+
+func (ctx *CtxVal) GetPath() string {
+	return ctx.path
+}
+
+func (ctx *CtxVal) Set(key string, val interface{}) {
+	ctx.data.Set(key, val)
+}
+
+func (ctx *CtxVal) Get(key string) (interface{}, bool) {
+	return ctx.data.Get(key)
+}
+
+// Value returns a static attribute of the running endpoint, see OptionsValue.
+func (ctx *CtxVal) Value(key string) (interface{}, bool) {
+	v, ok := ctx.values[key]
+	return v, ok
+}
+
+// Clone copies ctx for work that outlives the request, like a background refresh.
+func (ctx *CtxVal) Clone() *CtxVal {
+	out := NewCtxVal()
+	for k, v := range ctx.data.GetMap() {
+		out.data.Set(k, v)
+	}
+	out.protocol = ctx.protocol
+	out.method = ctx.method
+	out.path = ctx.path
+	out.values = ctx.values
+	return out
+}
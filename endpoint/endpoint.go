@@ -1,6 +1,9 @@
 package endpoint
 
-import "context"
+import (
+	"context"
+	"github.com/zander-84/gull/think"
+)
 
 type Endpoint func(ctx context.Context, request interface{}, dec DecodeRequestFunc, enc EncodeResponseFunc, errorEncoder ErrorEncoder, recoverEncoder RecoverEncoder) (response interface{}, err error)
 
@@ -27,23 +30,21 @@ func WrapError(e map[Protocol]func(ctx context.Context, err error)) ErrorEncoder
 	}
 }
 
-type RecoverEncoder func(ctx context.Context, p Protocol)
+// RecoverEncoder turns a value recovered from a panic of the endpoint into
+// the error handed to the ErrorEncoder.
+type RecoverEncoder func(ctx context.Context, p Protocol, rec interface{}) error
 
-func WrapRecover(rec map[Protocol]func(ctx context.Context)) RecoverEncoder {
+// WrapRecover picks a RecoverEncoder per protocol, think.Recover serves the others.
+func WrapRecover(rec map[Protocol]func(ctx context.Context, rec interface{}) error) RecoverEncoder {
 	if rec == nil {
 		return nil
 	}
 
-	return func(ctx context.Context, p Protocol) {
-		for k, v := range rec {
-			if k == p {
-				if v == nil {
-					break
-				}
-				v(ctx)
-				break
-			}
+	return func(ctx context.Context, p Protocol, r interface{}) error {
+		if v := rec[p]; v != nil {
+			return v(ctx, r)
 		}
+		return think.Recover(ctx, r)
 	}
 }
 
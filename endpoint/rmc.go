@@ -3,6 +3,8 @@ package endpoint
 import (
 	"context"
 	"errors"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
 	"log"
 	"strings"
 )
@@ -23,6 +25,9 @@ type Conf struct {
 	ps         []Protocol
 	Middleware Middleware
 
+	// Interceptor wraps the whole call, decoding, encoding and error encoding included.
+	Interceptor Middleware
+
 	HandlerFunc    HandlerFunc
 	RecoverEncoder RecoverEncoder
 
@@ -30,6 +35,9 @@ type Conf struct {
 	EncFunc EncodeResponseFunc
 
 	ErrorEncoder ErrorEncoder
+
+	// Values are static endpoint attributes read by middleware through CtxVal.Value.
+	Values map[string]interface{}
 }
 
 func newRmcConf() Conf {
@@ -37,6 +45,9 @@ func newRmcConf() Conf {
 		DecFunc: func(ctx context.Context, protocol Protocol, request interface{}) (response interface{}, err error) {
 			return
 		},
+		RecoverEncoder: func(ctx context.Context, p Protocol, rec interface{}) error {
+			return think.Recover(ctx, rec)
+		},
 	}
 	return out
 }
@@ -48,6 +59,15 @@ func OptionsMiddleware(m ...Middleware) Options {
 		rmc.Middleware = ChainMerge(rmc.Middleware, m...)
 	}
 }
+
+// OptionsInterceptor adds middleware around the whole endpoint call, so it sees
+// what the encoders wrote, unlike OptionsMiddleware which only wraps the handler.
+func OptionsInterceptor(m ...Middleware) Options {
+	return func(rmc *Conf) {
+		rmc.Interceptor = ChainMerge(rmc.Interceptor, m...)
+	}
+}
+
 func OptionsDec(Dec DecodeRequestFunc) Options {
 	return func(rmc *Conf) {
 		rmc.DecFunc = Dec
@@ -68,9 +88,24 @@ func OptionsErrorEncoder(ee ErrorEncoder) Options {
 	}
 }
 
+// OptionsValue attaches a static attribute to the endpoint or group.
+func OptionsValue(key string, val interface{}) Options {
+	return func(rmc *Conf) {
+		values := make(map[string]interface{}, len(rmc.Values)+1)
+		for k, v := range rmc.Values {
+			values[k] = v
+		}
+		values[key] = val
+		rmc.Values = values
+	}
+}
+
+// OptionsRecoverEncoder replaces think.Recover, the default way panics become errors.
 func OptionsRecoverEncoder(re RecoverEncoder) Options {
 	return func(rmc *Conf) {
-		rmc.RecoverEncoder = re
+		if re != nil {
+			rmc.RecoverEncoder = re
+		}
 	}
 }
 
@@ -117,7 +152,7 @@ func (r *rmc) GetEndpoint(p Protocol, method Method, path string) (HandlerFunc,
 		return nil, err
 	}
 	if inProtocols(p, conf.ps) {
-		return r._endpoint(conf.HandlerFunc, conf.DecFunc, conf.EncFunc, conf.Middleware, conf.ErrorEncoder, conf.RecoverEncoder), nil
+		return r._endpoint(conf), nil
 	}
 	return nil, errors.New("404")
 }
@@ -127,7 +162,7 @@ func (r *rmc) MustGetEndpoint(method Method, path string) HandlerFunc {
 	if err != nil {
 		panic("miss endpoint method: 【" + string(method) + "】 path: 【" + path + "】")
 	}
-	return r._endpoint(conf.HandlerFunc, conf.DecFunc, conf.EncFunc, conf.Middleware, conf.ErrorEncoder, conf.RecoverEncoder)
+	return r._endpoint(conf)
 }
 
 func (r *rmc) Endpoint(ps []Protocol, method Method, path string, hf HandlerFunc, dec DecodeRequestFunc, enc EncodeResponseFunc, options ...Options) {
@@ -165,14 +200,39 @@ func (r *rmc) getConfig(method Method, path string) (*Conf, error) {
 	return &conf, nil
 
 }
-func (r *rmc) _endpoint(hf HandlerFunc, dec DecodeRequestFunc, enc EncodeResponseFunc, middleware Middleware, errorEncoder ErrorEncoder, recoverEncoder RecoverEncoder) HandlerFunc {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
+func (r *rmc) _endpoint(conf *Conf) HandlerFunc {
+	hf, dec, enc, middleware := conf.HandlerFunc, conf.DecFunc, conf.EncFunc, conf.Middleware
+	errorEncoder, recoverEncoder := conf.ErrorEncoder, conf.RecoverEncoder
+	// recovered turns a panic into an error and encodes it, unless the
+	// response was already sent, it must be called from the deferred func
+	// which recovered
+	recovered := func(ctx context.Context, rec interface{}) error {
 		protocol := MustGetCtxVal(ctx).GetProtocol()
+		var err error
 		if recoverEncoder != nil {
-			defer recoverEncoder(ctx, protocol)
+			err = recoverEncoder(ctx, protocol, rec)
+		} else {
+			err = think.Recover(ctx, rec)
+		}
+		if c, ok := ctx.(interface{ Written() bool }); ok && c.Written() {
+			log.Printf("[Endpoint] %s %s panicked after its response was sent: %v", conf.Method, conf.Path, err)
+		} else if errorEncoder != nil {
+			errorEncoder(ctx, protocol, err)
+		} else {
+			encodeError(ctx, err)
 		}
+		return err
+	}
+	call := func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+		protocol := MustGetCtxVal(ctx).GetProtocol()
+		// recovered here, interceptors see the panic as an error
+		defer func() {
+			if rec := recover(); rec != nil {
+				resp, err = nil, recovered(ctx, rec)
+			}
+		}()
 
-		resp, err := middleware(func(hf HandlerFunc) HandlerFunc {
+		resp, err = middleware(func(hf HandlerFunc) HandlerFunc {
 			return func(ctx context.Context, data interface{}) (interface{}, error) {
 				var err error
 
@@ -194,12 +254,48 @@ func (r *rmc) _endpoint(hf HandlerFunc, dec DecodeRequestFunc, enc EncodeRespons
 			resp, err = enc(ctx, protocol, resp)
 		}
 
-		if err != nil && errorEncoder != nil {
-			errorEncoder(ctx, protocol, err)
+		if err != nil {
+			if errorEncoder != nil {
+				errorEncoder(ctx, protocol, err)
+			} else {
+				encodeError(ctx, err)
+			}
 		}
 
 		return resp, err
 	}
+	if conf.Interceptor != nil {
+		call = conf.Interceptor(call)
+	}
+
+	return func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+		ctxVal := MustGetCtxVal(ctx)
+		ctxVal.SetRoute(conf.Method, conf.Path)
+		ctxVal.values = conf.Values
+		// and here for panics of the interceptors themselves
+		defer func() {
+			if rec := recover(); rec != nil {
+				resp, err = nil, recovered(ctx, rec)
+			}
+		}()
+		return call(ctx, request)
+	}
+}
+
+// encodeError answers err with think.Envelope when no ErrorEncoder is set,
+// on contexts that can write json like transport/http.Context.
+func encodeError(ctx context.Context, err error) {
+	c, ok := ctx.(interface {
+		JSON(int, interface{}) error
+	})
+	if !ok {
+		return
+	}
+	var acceptLanguage string
+	if tr, ok := transport.FromServerContext(ctx); ok {
+		acceptLanguage = tr.RequestHeader().Get("Accept-Language")
+	}
+	_ = c.JSON(think.Envelope(err, acceptLanguage))
 }
 
 func (r *rmc) Proxy(proxy ProxyEndpoint, protocol Protocol) {
@@ -209,7 +305,7 @@ func (r *rmc) Proxy(proxy ProxyEndpoint, protocol Protocol) {
 			return
 		}
 		if inProtocols(protocol, conf.ps) {
-			proxy(protocol, v.Method, v.Path, r._endpoint(conf.HandlerFunc, v.DecFunc, v.EncFunc, conf.Middleware, v.ErrorEncoder, conf.RecoverEncoder))
+			proxy(protocol, v.Method, v.Path, r._endpoint(conf))
 		}
 
 	}
@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"github.com/zander-84/gull/think"
+	"testing"
+)
+
+type jsonCtx struct {
+	context.Context
+	code   int
+	body   interface{}
+	writes int // after the first
+}
+
+func (c *jsonCtx) JSON(code int, v interface{}) error {
+	if c.code != 0 {
+		c.writes++
+		return nil
+	}
+	c.code, c.body = code, v
+	return nil
+}
+
+func (c *jsonCtx) Written() bool { return c.code != 0 }
+
+func panicking(ctx context.Context, request interface{}) (interface{}, error) {
+	panic("boom")
+}
+
+func call(r Rmc) (*jsonCtx, error) {
+	ctx := &jsonCtx{Context: WithContext(context.Background(), nil)}
+	MustGetCtxVal(ctx).SetProtocol(Http)
+	_, err := r.MustGetEndpoint(MethodGet, "/p")(ctx, nil)
+	return ctx, err
+}
+
+func TestRecoverDefault(t *testing.T) {
+	r := NewRmc()
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", panicking, nil, nil)
+
+	ctx, err := call(r)
+	e := think.FromError(err)
+	if e == nil || e.Code != think.CodePanicError || e.Metadata[think.PanicIDKey] == "" {
+		t.Fatalf("err = %v, want a CodePanicError with a panic id", err)
+	}
+	if errors.Unwrap(e) == nil || errors.Unwrap(e).Error() != "boom" {
+		t.Fatal("the panic value should be the cause")
+	}
+	if ctx.code != 500 || ctx.body.(think.Response).Code != think.CodePanicError {
+		t.Fatalf("wrote %d %v, want a 500 envelope", ctx.code, ctx.body)
+	}
+}
+
+func TestRecoverInterceptor(t *testing.T) {
+	var seen error
+	r := NewRmc().Use(OptionsInterceptor(
+		func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				panic("interceptor")
+			}
+		},
+	))
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	}, nil, nil)
+	ctx, err := call(r)
+	if !think.IsErrPanic(err) || ctx.code != 500 {
+		t.Fatalf("err = %v, wrote %d, a panicking interceptor should be recovered", err, ctx.code)
+	}
+
+	// handler panics are still recovered inside the interceptors
+	r = NewRmc().Use(OptionsInterceptor(
+		func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				resp, err := next(ctx, request)
+				seen = err
+				return resp, err
+			}
+		},
+	))
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", panicking, nil, nil)
+	if _, err := call(r); !think.IsErrPanic(seen) || seen != err {
+		t.Fatalf("interceptor saw %v, want the panic error", seen)
+	}
+
+	// a panic once the response is sent is not answered a second time
+	r = NewRmc().Use(OptionsInterceptor(
+		func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				resp, _ := next(ctx, request)
+				_ = ctx.(*jsonCtx).JSON(200, resp)
+				panic("after write")
+			}
+		},
+	))
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	}, nil, nil)
+	if ctx, err := call(r); !think.IsErrPanic(err) || ctx.code != 200 || ctx.writes != 0 {
+		t.Fatalf("err = %v, wrote %d then %d more", err, ctx.code, ctx.writes)
+	}
+}
+
+func TestRecoverEncoders(t *testing.T) {
+	var encoded error
+	r := NewRmc().Use(
+		OptionsErrorEncoder(func(ctx context.Context, p Protocol, err error) { encoded = err }),
+		OptionsRecoverEncoder(WrapRecover(map[Protocol]func(ctx context.Context, rec interface{}) error{
+			Grpc: func(ctx context.Context, rec interface{}) error { return errors.New("grpc") },
+		})),
+	)
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", panicking, nil, nil)
+
+	ctx, err := call(r)
+	if !think.IsErrPanic(err) || encoded != err {
+		t.Fatalf("err = %v, encoded = %v", err, encoded)
+	}
+	if ctx.code != 0 {
+		t.Fatal("the error encoder should answer instead of the fallback")
+	}
+}
+
+func TestDefaultErrorEnvelope(t *testing.T) {
+	r := NewRmc()
+	r.Endpoint([]Protocol{Http}, MethodGet, "/p", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, think.New(think.CodeNotFound, "", "missing", "")
+	}, nil, nil)
+
+	ctx, _ := call(r)
+	if ctx.code != 400 || ctx.body.(think.Response).Message != "missing" {
+		t.Fatalf("wrote %d %v", ctx.code, ctx.body)
+	}
+}
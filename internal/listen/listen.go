@@ -0,0 +1,176 @@
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zander-84/gull/internal/endpoint"
+	"github.com/zander-84/gull/internal/host"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	unixPrefix    = "unix://"
+	systemdPrefix = "systemd://"
+	tcpPrefix     = "tcp://"
+)
+
+// Listen opens the listener of a server address:
+//
+//	127.0.0.1:8000 or tcp://127.0.0.1:8000  on network, tcp by default
+//	unix:///run/app.sock                    a unix socket, a stale file is removed
+//	systemd://                              the first socket passed by systemd
+//	systemd://http                          the socket named http by FileDescriptorName=
+//	systemd://1                             the second socket passed by systemd
+//
+// A process started by an upgrade gets the listener of its parent for the
+// same network and address, see Files.
+func Listen(network, address string) (net.Listener, error) {
+	key := network + " " + address
+	lis, err := inherited(key)
+	if err == nil && lis == nil {
+		lis, err = open(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	remember(key, lis)
+	return lis, nil
+}
+
+func open(network, address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, unixPrefix):
+		return listenUnix(strings.TrimPrefix(address, unixPrefix))
+	case strings.HasPrefix(address, systemdPrefix):
+		return systemd(strings.TrimPrefix(address, systemdPrefix))
+	default:
+		return net.Listen(network, strings.TrimPrefix(address, tcpPrefix))
+	}
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		// a socket nobody answers on is left over by a process which died
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("listen unix %s: address already in use", path)
+		}
+		_ = os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
+// Endpoint returns the registry url of lis served with scheme. Unix sockets
+// give scheme+"+unix" urls holding the socket path, like grpc+unix:///run/app.sock.
+func Endpoint(scheme, address string, lis net.Listener) (*url.URL, error) {
+	if addr, ok := lis.Addr().(*net.UnixAddr); ok {
+		return &url.URL{Scheme: scheme + "+unix", Path: addr.Name}, nil
+	}
+	if strings.HasPrefix(address, systemdPrefix) {
+		// the socket is bound by systemd, maybe on all interfaces
+		address = lis.Addr().String()
+	}
+	addr, err := host.Extract(strings.TrimPrefix(address, tcpPrefix), lis)
+	if err != nil {
+		return nil, err
+	}
+	return endpoint.NewEndpoint(scheme, addr), nil
+}
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+var activated struct {
+	once  sync.Once
+	err   error
+	names []string
+	files []*os.File
+	taken []bool
+	lock  sync.Mutex
+}
+
+// systemd returns the socket passed with LISTEN_FDS picked by name or index,
+// each socket can be taken once.
+func systemd(name string) (net.Listener, error) {
+	activated.once.Do(func() {
+		activated.names, activated.files, activated.err = inherit()
+		activated.taken = make([]bool, len(activated.files))
+	})
+	if activated.err != nil {
+		return nil, activated.err
+	}
+	activated.lock.Lock()
+	defer activated.lock.Unlock()
+
+	i := 0
+	if name != "" {
+		i = -1
+		for j, n := range activated.names {
+			if n == name {
+				i = j
+				break
+			}
+		}
+		if n, err := strconv.Atoi(name); i < 0 && err == nil {
+			i = n
+		}
+	}
+	if i < 0 || i >= len(activated.files) {
+		return nil, fmt.Errorf("listen systemd: no socket %q among %v", name, activated.names)
+	}
+	if activated.taken[i] {
+		return nil, fmt.Errorf("listen systemd: socket %q is already taken", name)
+	}
+	lis, err := net.FileListener(activated.files[i])
+	if err != nil {
+		return nil, err
+	}
+	activated.taken[i] = true
+	_ = activated.files[i].Close()
+	return lis, nil
+}
+
+// inherit reads the sockets of the sd_listen_fds protocol.
+func inherit() ([]string, []*os.File, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, nil, errors.New("listen systemd: no socket passed to this process")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil, errors.New("listen systemd: no socket passed to this process")
+	}
+	fdNames := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	names := make([]string, n)
+	files := make([]*os.File, n)
+	for i := range files {
+		fd := listenFdsStart + i
+		if i < len(fdNames) && fdNames[i] != "" {
+			names[i] = fdNames[i]
+		} else {
+			names[i] = "LISTEN_FD_" + strconv.Itoa(fd)
+		}
+		files[i] = os.NewFile(uintptr(fd), names[i])
+	}
+	return names, files, nil
+}
+
+// Accepting returns lis calling ready once, when a server first accepts on it.
+func Accepting(lis net.Listener, ready func()) net.Listener {
+	return &accepting{Listener: lis, ready: ready}
+}
+
+type accepting struct {
+	net.Listener
+	once  sync.Once
+	ready func()
+}
+
+func (l *accepting) Accept() (net.Conn, error) {
+	l.once.Do(l.ready)
+	return l.Listener.Accept()
+}
@@ -0,0 +1,155 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	lis, err := Listen("tcp", "unix://"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if conn, err := lis.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+	if _, err = Listen("tcp", "unix://"+path); err == nil {
+		t.Fatal("a socket in use should not be taken over")
+	}
+	u, err := Endpoint("grpc", "unix://"+path, lis)
+	if err != nil || u.String() != "grpc+unix://"+path {
+		t.Fatalf("endpoint = %v, %v", u, err)
+	}
+
+	// leave a stale socket file behind
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = lis.Close()
+	lis, err = Listen("tcp", "unix://"+path)
+	if err != nil {
+		t.Fatalf("a stale socket should be replaced: %v", err)
+	}
+	_ = lis.Close()
+}
+
+func TestHandOver(t *testing.T) {
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	// forget the listeners of the other tests, they are closed
+	opened.lock.Lock()
+	opened.keys, opened.lis = nil, nil
+	opened.lock.Unlock()
+
+	failed := filepath.Join(t.TempDir(), "failed.sock")
+	lis, err := Listen("tcp", "unix://"+failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		_ = f.Close()
+	}
+	// the upgrade failed, the socket is still removed on shutdown
+	_ = lis.Close()
+	if exists(failed) {
+		t.Fatal("passing the files should not keep the socket")
+	}
+
+	handed := filepath.Join(t.TempDir(), "handed.sock")
+	if lis, err = Listen("tcp", "unix://"+handed); err != nil {
+		t.Fatal(err)
+	}
+	HandOver()
+	_ = lis.Close()
+	if !exists(handed) {
+		t.Fatal("a handed over socket should stay for the new process")
+	}
+}
+
+func TestTCP(t *testing.T) {
+	lis, err := Listen("tcp", "tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	u, err := Endpoint("http", "tcp://127.0.0.1:0", lis)
+	if err != nil || u.String() != "http://"+lis.Addr().String() {
+		t.Fatalf("endpoint = %v, %v", u, err)
+	}
+}
+
+func TestSystemdWithoutSockets(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	if _, _, err := inherit(); err == nil {
+		t.Fatal("no LISTEN_PID means no sockets")
+	}
+}
+
+func TestSystemd(t *testing.T) {
+	if os.Getenv("LISTEN_FDS") != "" {
+		// the child: systemd would have set the pid
+		_ = os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		lis, err := Listen("tcp", "systemd://web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Listen("tcp", "systemd://web"); err == nil {
+			t.Fatal("a socket should be taken once")
+		}
+		u, _ := Endpoint("http", "systemd://web", lis)
+		fmt.Printf("addr=%s endpoint=%s\n", lis.Addr(), u)
+		return
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	f, err := lis.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemd$", "-test.v")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(), "LISTEN_FDS=1", "LISTEN_FDNAMES=web")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	want := fmt.Sprintf("addr=%s endpoint=http://%s", lis.Addr(), lis.Addr())
+	if !strings.Contains(string(out), want) {
+		t.Fatalf("want %q in %s", want, out)
+	}
+}
+
+func TestAccepting(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	lis = Accepting(lis, func() { calls++ })
+	_ = lis.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := lis.Accept(); err == nil {
+			t.Fatal("accept on a closed listener")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("ready called %d times, want once", calls)
+	}
+}
@@ -0,0 +1,103 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// EnvUpgrade lists the keys of the listeners passed to a process started by
+// an upgrade, in the order of their file descriptors from 3.
+const EnvUpgrade = "GULL_UPGRADE_LISTENERS"
+
+var opened struct {
+	lock sync.Mutex
+	keys []string
+	lis  map[string]net.Listener
+}
+
+func remember(key string, lis net.Listener) {
+	opened.lock.Lock()
+	defer opened.lock.Unlock()
+	if opened.lis == nil {
+		opened.lis = make(map[string]net.Listener)
+	}
+	if _, ok := opened.lis[key]; !ok {
+		opened.keys = append(opened.keys, key)
+	}
+	opened.lis[key] = lis
+}
+
+var parent struct {
+	once  sync.Once
+	files map[string]*os.File
+	lock  sync.Mutex
+}
+
+// inherited returns the listener of key passed by the parent, or nil.
+func inherited(key string) (net.Listener, error) {
+	parent.once.Do(func() {
+		parent.files = make(map[string]*os.File)
+		env := os.Getenv(EnvUpgrade)
+		if env == "" {
+			return
+		}
+		for i, k := range strings.Split(env, ",") {
+			parent.files[k] = os.NewFile(uintptr(listenFdsStart+i), k)
+		}
+	})
+	parent.lock.Lock()
+	defer parent.lock.Unlock()
+	f, ok := parent.files[key]
+	if !ok {
+		return nil, nil
+	}
+	delete(parent.files, key)
+	defer f.Close()
+	lis, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("listen: inherit %s: %w", key, err)
+	}
+	return lis, nil
+}
+
+// Files returns the files of the listeners opened by Listen and the value
+// of EnvUpgrade naming them, to pass them to another process as fds 3 and
+// following. Call HandOver once that process serves them.
+func Files() (string, []*os.File, error) {
+	opened.lock.Lock()
+	defer opened.lock.Unlock()
+	keys := make([]string, 0, len(opened.keys))
+	files := make([]*os.File, 0, len(opened.keys))
+	for _, key := range opened.keys {
+		lis, ok := opened.lis[key].(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := lis.File()
+		if err != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+			return "", nil, fmt.Errorf("listen: pass %s: %w", key, err)
+		}
+		keys = append(keys, key)
+		files = append(files, f)
+	}
+	return strings.Join(keys, ","), files, nil
+}
+
+// HandOver leaves the unix sockets of the listeners opened by Listen in
+// place when they are closed, as the process started by an upgrade serves
+// them now. Until then a failed upgrade keeps the usual cleanup on shutdown.
+func HandOver() {
+	opened.lock.Lock()
+	defer opened.lock.Unlock()
+	for _, key := range opened.keys {
+		if ul, ok := opened.lis[key].(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	"strconv"
+	"time"
+)
+
+// Middleware counts endpoint calls and their latency by protocol, method,
+// path and think.Code, and HTTP responses by the status written. Use it with
+// endpoint.OptionsInterceptor so the encoders are timed and counted too.
+func Middleware(reg *Registry) endpoint.Middleware {
+	requests := reg.NewCounter("gull_endpoint_requests_total", "Endpoint calls by result code.", "protocol", "method", "path", "code")
+	latency := reg.NewHistogram("gull_endpoint_request_duration_seconds", "Endpoint call latency.", nil, "protocol", "method", "path")
+	responses := reg.NewCounter("gull_endpoint_http_responses_total", "HTTP responses by status.", "method", "path", "status")
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			start := time.Now()
+			resp, err := next(ctx, request)
+
+			var protocol, method, path string
+			if v, ok := endpoint.GetCtxVal(ctx); ok {
+				protocol, method, path = string(v.GetProtocol()), string(v.GetMethod()), v.GetPath()
+			}
+			code := strconv.FormatUint(uint64(think.GetCode(err)), 10)
+			requests.Inc(protocol, method, path, code)
+			latency.Observe(time.Since(start).Seconds(), protocol, method, path)
+			if hc, ok := ctx.(http.Context); ok && hc.Response().Written() {
+				responses.Inc(method, path, strconv.Itoa(hc.Response().Status()))
+			}
+			return resp, err
+		}
+	}
+}
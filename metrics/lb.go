@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/zander-84/gull/contrib/lb"
+	"github.com/zander-84/gull/registry"
+)
+
+type balancer struct {
+	lb.Balancer
+	service string
+	picks   *Counter
+}
+
+// Balancer counts the nodes b picks for service. It is bounded by the nodes
+// ever seen, unlike lb.Balancer.Used which needs the record flag.
+func Balancer(reg *Registry, service string, b lb.Balancer) lb.Balancer {
+	return &balancer{
+		Balancer: b,
+		service:  service,
+		picks:    reg.NewCounter("gull_lb_picks_total", "Nodes picked by the balancer.", "service", "node"),
+	}
+}
+
+func (b *balancer) Next() (any, error) {
+	node, err := b.Balancer.Next()
+	if err == nil {
+		b.picks.Inc(b.service, nodeLabel(node))
+	}
+	return node, err
+}
+
+func (b *balancer) Get(uid any) (any, error) {
+	node, err := b.Balancer.Get(uid)
+	if err == nil {
+		b.picks.Inc(b.service, nodeLabel(node))
+	}
+	return node, err
+}
+
+func nodeLabel(node any) string {
+	switch n := node.(type) {
+	case *registry.ServiceInstance:
+		return n.ID
+	case string:
+		return n
+	case fmt.Stringer:
+		return n.String()
+	default:
+		return fmt.Sprint(n)
+	}
+}
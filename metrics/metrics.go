@@ -0,0 +1,196 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format.
+//
+//	reg := metrics.NewRegistry()
+//	rmc.Use(endpoint.OptionsInterceptor(metrics.Middleware(reg)))
+//	mux.Handle("/metrics", reg)
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Registry holds metrics by name.
+type Registry struct {
+	lock    sync.RWMutex
+	metrics map[string]*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// register returns the metric called name, creating it on first use.
+// Asking for an existing name with another kind or labels panics.
+func (r *Registry) register(kind, name, help string, labels []string, buckets []float64) *metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.kind != kind || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s registered as %s%v", name, m.kind, m.labels))
+		}
+		return m
+	}
+	m := &metric{
+		kind:    kind,
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.metrics[name] = m
+	return m
+}
+
+// NewCounter returns the counter called name.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: r.register(kindCounter, name, help, labels, nil)}
+}
+
+// NewGauge returns the gauge called name.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: r.register(kindGauge, name, help, labels, nil)}
+}
+
+// NewHistogram returns the histogram called name, buckets default to DefBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{m: r.register(kindHistogram, name, help, labels, buckets)}
+}
+
+type metric struct {
+	kind    string
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64  // counter and gauge
+	counts []uint64 // histogram, per bucket, not cumulative
+	count  uint64
+}
+
+// with returns the series for values, the caller holds m.lock.
+func (m *metric) with(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if m.kind == kindHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Counter only goes up.
+type Counter struct {
+	m *metric
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.m.lock.Lock()
+	c.m.with(values).value += v
+	c.m.lock.Unlock()
+}
+
+// Value returns the current value of the series.
+func (c *Counter) Value(values ...string) float64 {
+	c.m.lock.Lock()
+	defer c.m.lock.Unlock()
+	return c.m.with(values).value
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	m *metric
+}
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.m.lock.Lock()
+	g.m.with(values).value = v
+	g.m.lock.Unlock()
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.m.lock.Lock()
+	g.m.with(values).value += v
+	g.m.lock.Unlock()
+}
+
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+// Value returns the current value of the series.
+func (g *Gauge) Value(values ...string) float64 {
+	g.m.lock.Lock()
+	defer g.m.lock.Unlock()
+	return g.m.with(values).value
+}
+
+// Delete drops the series, like the gauge of a node that left.
+func (g *Gauge) Delete(values ...string) {
+	g.m.lock.Lock()
+	delete(g.m.series, strings.Join(values, "\xff"))
+	g.m.lock.Unlock()
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	m *metric
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	i := sort.SearchFloat64s(h.m.buckets, v)
+	h.m.lock.Lock()
+	s := h.m.with(values)
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.value += v
+	h.m.lock.Unlock()
+}
+
+// Count returns the number of observations of the series.
+func (h *Histogram) Count(values ...string) uint64 {
+	h.m.lock.Lock()
+	defer h.m.lock.Unlock()
+	return h.m.with(values).count
+}
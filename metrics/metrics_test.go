@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/zander-84/gull/contrib/lb"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/registry"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	http2 "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http2.MethodGet, "/metrics", nil))
+	if rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("content type %s", rec.Header().Get("Content-Type"))
+	}
+	return rec.Body.String()
+}
+
+func contains(t *testing.T, text string, lines ...string) {
+	for _, l := range lines {
+		if !strings.Contains(text, l+"\n") {
+			t.Fatalf("missing %q in\n%s", l, text)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("jobs_total", "Jobs done.", "queue")
+	c.Inc("a\"b")
+	c.Add(2, "c")
+	h := reg.NewHistogram("took_seconds", "Job time.", []float64{1, 0.5})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	contains(t, scrape(t, reg),
+		"# HELP jobs_total Jobs done.",
+		"# TYPE jobs_total counter",
+		`jobs_total{queue="a\"b"} 1`,
+		`jobs_total{queue="c"} 2`,
+		"# TYPE took_seconds histogram",
+		`took_seconds_bucket{le="0.5"} 1`,
+		`took_seconds_bucket{le="1"} 2`,
+		`took_seconds_bucket{le="+Inf"} 3`,
+		"took_seconds_sum 3.9",
+		"took_seconds_count 3",
+	)
+
+	if reg.NewCounter("jobs_total", "Jobs done.", "queue").Value("c") != 2 {
+		t.Fatal("registering a name twice should return the same metric")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a name with other labels should panic")
+		}
+	}()
+	reg.NewGauge("jobs_total", "")
+}
+
+func TestMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	var err error
+	h := Middleware(reg)(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, err
+	})
+	v := endpoint.NewCtxVal()
+	v.SetProtocol(endpoint.Http)
+	v.SetRoute(endpoint.MethodGet, "/users")
+	ctx := endpoint.WithContext(context.Background(), v)
+	_, _ = h(ctx, nil)
+	err = think.New(think.CodeNotFound, "", "", "")
+	_, _ = h(ctx, nil)
+
+	contains(t, scrape(t, reg),
+		`gull_endpoint_requests_total{protocol="HTTP",method="GET",path="/users",code="100200"} 1`,
+		`gull_endpoint_requests_total{protocol="HTTP",method="GET",path="/users",code="100404"} 1`,
+		`gull_endpoint_request_duration_seconds_count{protocol="HTTP",method="GET",path="/users"} 2`,
+	)
+}
+
+func TestMiddlewareStatus(t *testing.T) {
+	reg := NewRegistry()
+	h := Middleware(reg)(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, ctx.(http.Context).String(http2.StatusTeapot, "short and stout")
+	})
+	v := endpoint.NewCtxVal()
+	v.SetProtocol(endpoint.Http)
+	v.SetRoute(endpoint.MethodGet, "/pot")
+	req := httptest.NewRequest(http2.MethodGet, "/pot", nil)
+	req = req.WithContext(endpoint.WithContext(req.Context(), v))
+	_, _ = h(http.NewHttpContext(httptest.NewRecorder(), req), nil)
+
+	contains(t, scrape(t, reg),
+		`gull_endpoint_http_responses_total{method="GET",path="/pot",status="418"} 1`,
+	)
+}
+
+func TestInFlight(t *testing.T) {
+	reg := NewRegistry()
+	var during float64
+	h := Handler(reg, "api", http2.HandlerFunc(func(w http2.ResponseWriter, r *http2.Request) {
+		during = inFlight(reg).Value("api")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http2.MethodGet, "/", nil))
+	if during != 1 || inFlight(reg).Value("api") != 0 {
+		t.Fatalf("during = %v, after = %v", during, inFlight(reg).Value("api"))
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	reg := NewRegistry()
+	l := lb.NewListener("svc")
+	_ = l.Add(&registry.ServiceInstance{ID: "n1"})
+	b := Balancer(reg, "svc", lb.NewBalancer(l, lb.RoundRobin, false))
+	for i := 0; i < 3; i++ {
+		if _, err := b.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	contains(t, scrape(t, reg), `gull_lb_picks_total{service="svc",node="n1"} 3`)
+}
+
+type fakeDiscovery struct {
+	registry.Discovery
+	results []error
+}
+
+func (d *fakeDiscovery) Watch(ctx context.Context, serviceName string) (registry.Watcher, error) {
+	return &fakeWatcher{d: d}, nil
+}
+
+type fakeWatcher struct {
+	registry.Watcher
+	d *fakeDiscovery
+}
+
+func (w *fakeWatcher) Next() ([]*registry.ServiceInstance, error) {
+	err := w.d.results[0]
+	w.d.results = w.d.results[1:]
+	if err != nil {
+		return nil, err
+	}
+	return []*registry.ServiceInstance{{ID: "a"}, {ID: "b"}}, nil
+}
+
+func TestDiscovery(t *testing.T) {
+	reg := NewRegistry()
+	d := Discovery(reg, &fakeDiscovery{results: []error{nil, errors.New("lost")}})
+	w, _ := d.Watch(context.Background(), "svc")
+	_, _ = w.Next()
+	_, _ = w.Next()
+	contains(t, scrape(t, reg),
+		`gull_registry_instances{service="svc"} 2`,
+		`gull_registry_watch_events_total{service="svc",result="error"} 1`,
+		`gull_registry_watch_events_total{service="svc",result="update"} 1`,
+	)
+}
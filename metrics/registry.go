@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"github.com/zander-84/gull/registry"
+)
+
+type discovery struct {
+	registry.Discovery
+	events    *Counter
+	instances *Gauge
+}
+
+// Discovery counts the watch events of d and the instances each service has.
+func Discovery(reg *Registry, d registry.Discovery) registry.Discovery {
+	return &discovery{
+		Discovery: d,
+		events:    reg.NewCounter("gull_registry_watch_events_total", "Registry watch events by result.", "service", "result"),
+		instances: reg.NewGauge("gull_registry_instances", "Instances last seen by the registry watch.", "service"),
+	}
+}
+
+func (d *discovery) Watch(ctx context.Context, serviceName string) (registry.Watcher, error) {
+	w, err := d.Discovery.Watch(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return &watcher{Watcher: w, d: d, service: serviceName}, nil
+}
+
+type watcher struct {
+	registry.Watcher
+	d       *discovery
+	service string
+}
+
+func (w *watcher) Next() ([]*registry.ServiceInstance, error) {
+	items, err := w.Watcher.Next()
+	if err != nil {
+		w.d.events.Inc(w.service, "error")
+		return items, err
+	}
+	w.d.events.Inc(w.service, "update")
+	w.d.instances.Set(float64(len(items)), w.service)
+	return items, nil
+}
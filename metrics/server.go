@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"net/http"
+)
+
+func inFlight(reg *Registry) *Gauge {
+	return reg.NewGauge("gull_server_in_flight_requests", "Requests being served.", "server")
+}
+
+// Handler counts the requests h is serving under the server label, for
+// transport/http.ServerHandler.
+func Handler(reg *Registry, server string, h http.Handler) http.Handler {
+	g := inFlight(reg)
+	g.Set(0, server)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Inc(server)
+		defer g.Dec(server)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor counts the unary calls a grpc server is serving under the server label.
+func UnaryServerInterceptor(reg *Registry, server string) grpc.UnaryServerInterceptor {
+	g := inFlight(reg)
+	g.Set(0, server)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		g.Inc(server)
+		defer g.Dec(server)
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor counts the open streams of a grpc server under the server label.
+func StreamServerInterceptor(reg *Registry, server string) grpc.StreamServerInterceptor {
+	g := inFlight(reg)
+	g.Set(0, server)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		g.Inc(server)
+		defer g.Dec(server)
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP writes every metric in the Prometheus text format, so the
+// registry can be mounted on any http server.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.WriteText(w)
+}
+
+// WriteText writes every metric in the Prometheus text format, ordered by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]*metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.lock.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.series) == 0 {
+		return
+	}
+	w.WriteString("# HELP " + m.name + " " + escapeHelp(m.help) + "\n")
+	w.WriteString("# TYPE " + m.name + " " + m.kind + "\n")
+
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := m.series[k]
+		if m.kind != kindHistogram {
+			writeSample(w, m.name, m.labels, s.values, "", "", s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, m.name+"_bucket", m.labels, s.values, "le", formatFloat(le), float64(cumulative))
+		}
+		writeSample(w, m.name+"_bucket", m.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, m.name+"_sum", m.labels, s.values, "", "", s.value)
+		writeSample(w, m.name+"_count", m.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraLabel + `="` + extraValue + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,279 @@
+// Package accesslog records one entry per endpoint call.
+//
+// Install it with endpoint.OptionsInterceptor rather than OptionsMiddleware
+// so the entry includes what the encoders wrote:
+//
+//	rmc.Use(endpoint.OptionsInterceptor(accesslog.New(accesslog.WithSampleRate(0.1))))
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
+	"github.com/zander-84/gull/transport/http"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"math/rand"
+	http2 "net/http"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the values of sensitive headers and body fields.
+const Redacted = "***"
+
+// Entry is the record of one endpoint call.
+type Entry struct {
+	Time      time.Time
+	Protocol  endpoint.Protocol
+	Method    endpoint.Method
+	Path      string
+	Status    int // http status, 0 for other protocols
+	Code      think.Code
+	Error     string
+	Latency   time.Duration
+	Bytes     int64
+	Peer      string
+	RequestID string
+	Slow      bool
+	Header    map[string]string
+	Body      string
+}
+
+// String formats e as a single log line.
+func (e *Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s status=%d code=%d latency=%s bytes=%d peer=%s request_id=%s",
+		e.Protocol, e.Method, e.Path, e.Status, e.Code, e.Latency, e.Bytes, e.Peer, e.RequestID)
+	if e.Slow {
+		b.WriteString(" slow=true")
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&b, " err=%q", e.Error)
+	}
+	if len(e.Header) > 0 {
+		fmt.Fprintf(&b, " header=%v", e.Header)
+	}
+	if e.Body != "" {
+		fmt.Fprintf(&b, " body=%s", e.Body)
+	}
+	return b.String()
+}
+
+// Logger writes an entry.
+type Logger func(ctx context.Context, e *Entry)
+
+// Option is an access log option.
+type Option func(*options)
+
+type options struct {
+	logger          Logger
+	sampleRate      float64
+	slow            time.Duration
+	requestIDHeader string
+	headers         []string
+	redactHeaders   map[string]bool
+	redactFields    map[string]bool
+	maxBody         int
+	random          func() float64
+}
+
+// WithLogger sets where entries go, default the standard log package.
+func WithLogger(logger Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithSampleRate logs the given fraction of successful calls, default 1.
+// Failed and slow calls are always logged.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) { o.sampleRate = rate }
+}
+
+// WithSlowThreshold always logs calls taking at least d and marks them slow.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(o *options) { o.slow = d }
+}
+
+// WithRequestIDHeader sets the header or grpc metadata read for the request id
+// when no endpoint.RequestIDKey was stored, default X-Request-Id.
+func WithRequestIDHeader(name string) Option {
+	return func(o *options) { o.requestIDHeader = name }
+}
+
+// WithHeaders records the given request headers.
+func WithHeaders(names ...string) Option {
+	return func(o *options) { o.headers = append(o.headers, names...) }
+}
+
+// WithRedactHeaders masks more headers, Authorization, Cookie and X-Api-Key are always masked.
+func WithRedactHeaders(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			o.redactHeaders[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// WithBody records up to max bytes of the request body, http bodies are
+// recorded as read by the handler and grpc requests as json.
+func WithBody(max int) Option {
+	return func(o *options) { o.maxBody = max }
+}
+
+// WithRedactFields masks json body fields with these names at any depth, matched case-insensitively.
+func WithRedactFields(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			o.redactFields[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// New returns a middleware recording every call, meant for endpoint.OptionsInterceptor.
+func New(opts ...Option) endpoint.Middleware {
+	o := options{
+		logger:          stdLogger,
+		sampleRate:      1,
+		requestIDHeader: "X-Request-Id",
+		redactHeaders:   map[string]bool{"authorization": true, "cookie": true, "x-api-key": true},
+		redactFields:    map[string]bool{"password": true},
+		random:          rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			start := time.Now()
+			var res http.ResponseWriter
+			var body *bodyRecorder
+			if hc, ok := ctx.(http.Context); ok {
+				req := hc.Request()
+				if o.maxBody > 0 && req.Body != nil {
+					body = &bodyRecorder{ReadCloser: req.Body, max: o.maxBody}
+					req.Body = body
+				}
+				res = hc.Response()
+			}
+
+			resp, err := next(ctx, request)
+
+			latency := time.Since(start)
+			slow := o.slow > 0 && latency >= o.slow
+			if err == nil && !slow && o.sampleRate < 1 && o.random() >= o.sampleRate {
+				return resp, err
+			}
+
+			e := &Entry{
+				Time:    start,
+				Code:    think.GetCode(err),
+				Latency: latency,
+				Slow:    slow,
+			}
+			if v, ok := endpoint.GetCtxVal(ctx); ok {
+				e.Protocol, e.Method, e.Path = v.GetProtocol(), v.GetMethod(), v.GetPath()
+				if id, ok := v.Get(endpoint.RequestIDKey); ok {
+					e.RequestID, _ = id.(string)
+				}
+			}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			if c, ok := ctx.(interface{ RemoteIP() string }); ok {
+				e.Peer = c.RemoteIP()
+			}
+			if tr, ok := transport.FromServerContext(ctx); ok {
+				header := tr.RequestHeader()
+				if e.RequestID == "" {
+					e.RequestID = header.Get(o.requestIDHeader)
+				}
+				e.Header = o.recordHeaders(header)
+			}
+			if res != nil {
+				e.Status, e.Bytes = res.Status(), res.Size()
+				if e.Status == 0 {
+					e.Status = http2.StatusOK
+				}
+			} else if m, ok := resp.(proto.Message); ok && err == nil {
+				e.Bytes = int64(proto.Size(m))
+			}
+			if body != nil {
+				e.Body = o.redactBody(body.buf)
+			} else if res == nil && o.maxBody > 0 && request != nil {
+				if data, mErr := json.Marshal(request); mErr == nil {
+					if len(data) > o.maxBody {
+						data = data[:o.maxBody]
+					}
+					e.Body = o.redactBody(data)
+				}
+			}
+
+			o.logger(ctx, e)
+			return resp, err
+		}
+	}
+}
+
+func (o *options) recordHeaders(header transport.Header) map[string]string {
+	if len(o.headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(o.headers))
+	for _, name := range o.headers {
+		v := header.Get(name)
+		if v == "" {
+			continue
+		}
+		if o.redactHeaders[strings.ToLower(name)] {
+			v = Redacted
+		}
+		out[name] = v
+	}
+	return out
+}
+
+// redactBody masks the configured fields of a json body. Bodies that are not
+// json, or were cut at the size limit, are masked whole while any field is configured.
+func (o *options) redactBody(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		if len(o.redactFields) > 0 {
+			return Redacted
+		}
+		return string(data)
+	}
+	out, err := json.Marshal(o.redact(v))
+	if err != nil {
+		return Redacted
+	}
+	return string(out)
+}
+
+func (o *options) redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if o.redactFields[strings.ToLower(k)] {
+				t[k] = Redacted
+			} else {
+				t[k] = o.redact(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = o.redact(val)
+		}
+	}
+	return v
+}
+
+func stdLogger(_ context.Context, e *Entry) {
+	log.Printf("[Access] %s", e)
+}
@@ -0,0 +1,104 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	"io"
+	http2 "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(hf endpoint.HandlerFunc, body string, opts ...Option) []*Entry {
+	var entries []*Entry
+	opts = append(opts, WithLogger(func(ctx context.Context, e *Entry) { entries = append(entries, e) }))
+	rmc := endpoint.NewRmc().Use(endpoint.OptionsInterceptor(New(opts...)))
+	rmc.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodPost, "/login", hf, nil,
+		func(ctx context.Context, p endpoint.Protocol, in interface{}) (interface{}, error) {
+			return in, ctx.(http.Context).JSON(http2.StatusOK, in)
+		},
+		endpoint.OptionsErrorEncoder(func(ctx context.Context, p endpoint.Protocol, err error) {
+			_ = ctx.(http.Context).JSON(think.GetCode(err).HttpCode(), think.FromError(err))
+		}))
+
+	req := httptest.NewRequest(http2.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("X-Request-Id", "rid-1")
+	req.Header.Set("Authorization", "Bearer secret")
+	ctxVal := endpoint.NewCtxVal()
+	ctxVal.SetProtocol(endpoint.Http)
+	req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+	_, _ = rmc.MustGetEndpoint(endpoint.MethodPost, "/login")(http.NewHttpContext(httptest.NewRecorder(), req), nil)
+	return entries
+}
+
+func readAll(ctx context.Context, request interface{}) (interface{}, error) {
+	data, err := io.ReadAll(ctx.(http.Context).Request().Body)
+	return string(data), err
+}
+
+func TestEntry(t *testing.T) {
+	entries := serve(readAll, `{"user":"a","Password":"p","nested":[{"password":"q"}]}`,
+		WithHeaders("Authorization"), WithBody(1024))
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	e := entries[0]
+	if e.Protocol != endpoint.Http || e.Method != endpoint.MethodPost || e.Path != "/login" {
+		t.Fatalf("unexpected route %s %s %s", e.Protocol, e.Method, e.Path)
+	}
+	if e.Status != http2.StatusOK || e.Code != think.CodeSuccess || e.Bytes == 0 {
+		t.Fatalf("status = %d, code = %d, bytes = %d", e.Status, e.Code, e.Bytes)
+	}
+	if e.RequestID != "rid-1" || e.Peer != "192.0.2.1" {
+		t.Fatalf("request id = %s, peer = %s", e.RequestID, e.Peer)
+	}
+	if e.Header["Authorization"] != Redacted {
+		t.Fatalf("authorization = %s, want it redacted", e.Header["Authorization"])
+	}
+	if strings.Contains(e.Body, `"p"`) || strings.Contains(e.Body, `"q"`) || !strings.Contains(e.Body, `"user":"a"`) {
+		t.Fatalf("body = %s", e.Body)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	entries := serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, think.New(think.CodeForbidden, "", "no", "")
+	}, "")
+	if e := entries[0]; e.Status != http2.StatusForbidden || e.Code != think.CodeForbidden || e.Error == "" {
+		t.Fatalf("status = %d, code = %d, err = %s", e.Status, e.Code, e.Error)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	var err error
+	hf := func(ctx context.Context, request interface{}) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return "ok", nil
+	}
+	never := func(o *options) { o.random = func() float64 { return 1 } }
+
+	if entries := serve(hf, "", WithSampleRate(0.5), never); len(entries) != 0 {
+		t.Fatal("unsampled success should not be logged")
+	}
+	err = errors.New("boom")
+	if entries := serve(hf, "", WithSampleRate(0.5), never); len(entries) != 1 {
+		t.Fatal("failures should always be logged")
+	}
+
+	err = nil
+	slow := func(ctx context.Context, request interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "ok", nil
+	}
+	entries := serve(slow, "", WithSampleRate(0), WithSlowThreshold(time.Millisecond))
+	if len(entries) != 1 || !entries[0].Slow {
+		t.Fatal("slow calls should always be logged")
+	}
+}
@@ -0,0 +1,21 @@
+package accesslog
+
+import "io"
+
+// bodyRecorder keeps the first max bytes read from a request body.
+type bodyRecorder struct {
+	io.ReadCloser
+	max int
+	buf []byte
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.max - len(b.buf); room > 0 && n > 0 {
+		if n < room {
+			room = n
+		}
+		b.buf = append(b.buf, p[:room]...)
+	}
+	return n, err
+}
@@ -0,0 +1,91 @@
+// Package apikey authenticates requests by an api key looked up in a store.
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"sync"
+)
+
+var ErrNotFound = errors.New("apikey: key not found")
+
+// Store resolves an api key to its owner.
+type Store interface {
+	Lookup(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore keeps sha256 digests of the keys in memory.
+type MemoryStore struct {
+	lock sync.RWMutex
+	keys map[[sha256.Size]byte]*auth.Principal
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[[sha256.Size]byte]*auth.Principal)}
+}
+
+func (m *MemoryStore) Add(key string, p *auth.Principal) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.keys[sha256.Sum256([]byte(key))] = p
+}
+
+func (m *MemoryStore) Remove(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.keys, sha256.Sum256([]byte(key)))
+}
+
+func (m *MemoryStore) Lookup(_ context.Context, key string) (*auth.Principal, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	p, ok := m.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+// Option is an api key option.
+type Option func(*options)
+
+type options struct {
+	header string
+}
+
+// WithHeader sets the header or metadata carrying the key, default X-Api-Key.
+func WithHeader(name string) Option {
+	return func(o *options) { o.header = name }
+}
+
+// New returns a middleware authenticating requests with keys of store.
+func New(store Store, opts ...Option) endpoint.Middleware {
+	o := options{header: "X-Api-Key"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			key := auth.Header(ctx, o.header)
+			if key == "" {
+				return nil, auth.ErrUnauthorized("missing api key")
+			}
+			p, err := store.Lookup(ctx, key)
+			if err != nil {
+				return nil, auth.ErrUnauthorized(err.Error())
+			}
+			if p.Type == "" {
+				cp := *p
+				cp.Type = "apikey"
+				p = &cp
+			}
+			auth.SetPrincipal(ctx, p)
+			return next(ctx, request)
+		}
+	}
+}
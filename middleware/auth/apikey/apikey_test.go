@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	store := NewMemoryStore()
+	store.Add("k-123", &auth.Principal{ID: "svc-a", Roles: []string{"reader"}})
+	h := New(store)(func(ctx context.Context, request interface{}) (interface{}, error) {
+		p, _ := auth.FromContext(ctx)
+		return p, nil
+	})
+
+	call := func(key string) (interface{}, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+		return h(grpc.NewGrpcContext(endpoint.WithContext(ctx, nil)), nil)
+	}
+
+	resp, err := call("k-123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := resp.(*auth.Principal); p.ID != "svc-a" || p.Type != "apikey" {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+	if _, err := call("nope"); think.GetCode(err) != think.CodeUnauthorized {
+		t.Fatalf("code = %v, want CodeUnauthorized", think.GetCode(err))
+	}
+}
@@ -0,0 +1,69 @@
+// Package auth holds the authenticated caller shared by the jwt, sign and
+// apikey middleware.
+package auth
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
+	"strings"
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	ID     string
+	Type   string // jwt, sign, apikey
+	Roles  []string
+	Claims map[string]interface{}
+}
+
+func (p *Principal) String() string {
+	return p.ID
+}
+
+// SetPrincipal stores p in the CtxVal of ctx.
+func SetPrincipal(ctx context.Context, p *Principal) {
+	if v, ok := endpoint.GetCtxVal(ctx); ok {
+		v.Set(endpoint.PrincipalKey, p)
+	}
+}
+
+// FromContext returns the principal stored in the CtxVal of ctx, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	v, ok := endpoint.GetCtxVal(ctx)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.Get(endpoint.PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := p.(*Principal)
+	return principal, ok
+}
+
+// Header returns a http header or grpc metadata value of the request.
+func Header(ctx context.Context, key string) string {
+	if tr, ok := transport.FromServerContext(ctx); ok {
+		return tr.RequestHeader().Get(key)
+	}
+	return ""
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(ctx context.Context) string {
+	h := Header(ctx, "Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+func ErrUnauthorized(reason string) error {
+	return think.New(think.CodeUnauthorized, "", think.CodeUnauthorized.ToString(), reason)
+}
+
+func ErrSign(reason string) error {
+	return think.New(think.CodeSignError, "", think.CodeSignError.ToString(), reason)
+}
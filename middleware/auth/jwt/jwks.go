@@ -0,0 +1,194 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("jwt: key not found")
+
+// KeySet is a JSON Web Key Set loaded from a file or url and cached for ttl.
+type KeySet struct {
+	load       func(ctx context.Context) ([]byte, error)
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	lock    sync.RWMutex
+	keys    map[string]interface{}
+	fetched time.Time
+}
+
+// NewFileKeySet reads the key set from path.
+func NewFileKeySet(path string, ttl time.Duration) *KeySet {
+	return newKeySet(func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}, ttl)
+}
+
+// NewURLKeySet fetches the key set from url.
+func NewURLKeySet(url string, ttl time.Duration) *KeySet {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return newKeySet(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwt: fetch jwks %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}, ttl)
+}
+
+func newKeySet(load func(ctx context.Context) ([]byte, error), ttl time.Duration) *KeySet {
+	return &KeySet{
+		load:       load,
+		ttl:        ttl,
+		minRefresh: 10 * time.Second,
+	}
+}
+
+// Key returns the key with id kid, refreshing the set when it expired or
+// the kid is unknown. An empty kid matches the only key of the set.
+func (ks *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	ks.lock.RLock()
+	key, ok := ks.lookup(kid)
+	stale := time.Since(ks.fetched) > ks.ttl
+	recent := time.Since(ks.fetched) < ks.minRefresh
+	ks.lock.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && recent {
+		return nil, ErrKeyNotFound
+	}
+	if err := ks.Refresh(ctx); err != nil {
+		if ok {
+			return key, nil // keep serving the cached key when the source is down
+		}
+		return nil, err
+	}
+
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	if key, ok = ks.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (ks *KeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// Refresh reloads the key set.
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	data, err := ks.load(ctx)
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	ks.fetched = time.Now()
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	ks.keys = keys
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS decodes RSA, EC and oct keys of a key set by kid.
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.decode()
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) decode() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return enc.DecodeString(k.K)
+	default:
+		return nil, nil // unknown key types are skipped
+	}
+}
@@ -0,0 +1,146 @@
+// Package jwt verifies HS, RS and ES signed bearer tokens.
+package jwt
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"time"
+)
+
+// Option is a jwt option.
+type Option func(*options)
+
+type options struct {
+	key        interface{}
+	keySet     *KeySet
+	algs       map[string]bool
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	requireExp bool
+	rolesClaim string
+	now        func() time.Time
+}
+
+// WithKey verifies tokens with a static key: []byte, *rsa.PublicKey or *ecdsa.PublicKey.
+func WithKey(key interface{}) Option {
+	return func(o *options) { o.key = key }
+}
+
+// WithKeySet verifies tokens with the key of the token kid.
+func WithKeySet(ks *KeySet) Option {
+	return func(o *options) { o.keySet = ks }
+}
+
+// WithAlgorithms restricts the accepted algorithms, all supported by default.
+func WithAlgorithms(algs ...string) Option {
+	return func(o *options) {
+		o.algs = make(map[string]bool, len(algs))
+		for _, a := range algs {
+			o.algs[a] = true
+		}
+	}
+}
+
+func WithIssuer(iss string) Option {
+	return func(o *options) { o.issuer = iss }
+}
+
+func WithAudience(aud string) Option {
+	return func(o *options) { o.audience = aud }
+}
+
+// WithLeeway tolerates clock skew on exp and nbf, default 1 minute.
+func WithLeeway(d time.Duration) Option {
+	return func(o *options) { o.leeway = d }
+}
+
+// WithRequireExp rejects tokens without exp, default true.
+func WithRequireExp(require bool) Option {
+	return func(o *options) { o.requireExp = require }
+}
+
+// WithRolesClaim sets the claim copied to Principal.Roles, default "roles".
+func WithRolesClaim(claim string) Option {
+	return func(o *options) { o.rolesClaim = claim }
+}
+
+// New returns a middleware verifying the bearer token of the Authorization
+// header or metadata and storing the principal in the CtxVal.
+func New(opts ...Option) endpoint.Middleware {
+	o := options{
+		leeway:     time.Minute,
+		requireExp: true,
+		rolesClaim: "roles",
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			raw := auth.BearerToken(ctx)
+			if raw == "" {
+				return nil, auth.ErrUnauthorized("missing bearer token")
+			}
+			t, err := o.verify(ctx, raw)
+			if err != nil {
+				return nil, auth.ErrUnauthorized(err.Error())
+			}
+			auth.SetPrincipal(ctx, &auth.Principal{
+				ID:     t.Claims.String("sub"),
+				Type:   "jwt",
+				Roles:  t.Claims.Strings(o.rolesClaim),
+				Claims: t.Claims,
+			})
+			return next(ctx, request)
+		}
+	}
+}
+
+func (o *options) verify(ctx context.Context, raw string) (*Token, error) {
+	t, err := Parse(raw, func(t *Token) (interface{}, error) {
+		if o.algs != nil && !o.algs[t.Alg()] {
+			return nil, ErrUnsupportedAlg
+		}
+		if o.keySet != nil {
+			return o.keySet.Key(ctx, t.Kid())
+		}
+		if o.key == nil {
+			return nil, ErrKeyNotFound
+		}
+		return o.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, o.validate(t.Claims)
+}
+
+func (o *options) validate(c Claims) error {
+	now := o.now()
+	exp, ok := c.Time("exp")
+	if !ok && o.requireExp {
+		return ErrNoExpiry
+	}
+	if ok && now.After(exp.Add(o.leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := c.Time("nbf"); ok && now.Add(o.leeway).Before(nbf) {
+		return ErrNotValidYet
+	}
+	if o.issuer != "" && c.String("iss") != o.issuer {
+		return ErrIssuer
+	}
+	if o.audience != "" {
+		for _, aud := range c.Strings("aud") {
+			if aud == o.audience {
+				return nil
+			}
+		}
+		return ErrAudience
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	"math/big"
+	http2 "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func call(m endpoint.Middleware, token string) (*auth.Principal, error) {
+	req := httptest.NewRequest(http2.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req = req.WithContext(endpoint.WithContext(req.Context(), nil))
+	var p *auth.Principal
+	_, err := m(func(ctx context.Context, request interface{}) (interface{}, error) {
+		p, _ = auth.FromContext(ctx)
+		return nil, nil
+	})(http.NewHttpContext(httptest.NewRecorder(), req), nil)
+	return p, err
+}
+
+func TestAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	secret := []byte("secret")
+	claims := Claims{"sub": "zander", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		alg    string
+		sign   interface{}
+		verify interface{}
+	}{
+		{"HS256", secret, secret},
+		{"RS256", rsaKey, &rsaKey.PublicKey},
+		{"ES256", ecKey, &ecKey.PublicKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			token, err := Sign(tt.alg, claims, tt.sign, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := call(New(WithKey(tt.verify)), token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ID != "zander" || len(p.Roles) != 1 || p.Roles[0] != "admin" {
+				t.Fatalf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestRejects(t *testing.T) {
+	secret := []byte("secret")
+	expired, _ := Sign("HS256", Claims{"exp": time.Now().Add(-time.Hour).Unix()}, secret, "")
+	exp := time.Now().Add(time.Hour).Unix()
+	forged, _ := Sign("HS256", Claims{"sub": "x", "exp": exp}, []byte("other"), "")
+	wrongAud, _ := Sign("HS256", Claims{"aud": "b", "exp": exp}, secret, "")
+	noExp, _ := Sign("HS256", Claims{"aud": "a"}, secret, "")
+
+	m := New(WithKey(secret), WithAudience("a"))
+	for name, token := range map[string]string{"missing": "", "expired": expired, "forged": forged, "audience": wrongAud, "no exp": noExp, "garbage": "a.b.c"} {
+		if _, err := call(m, token); think.GetCode(err) != think.CodeUnauthorized {
+			t.Errorf("%s: code = %v, want CodeUnauthorized", name, think.GetCode(err))
+		}
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token, _ := Sign("HS256", Claims{}, []byte("x"), "")
+	if _, err := call(New(WithKey(&rsaKey.PublicKey)), token); err == nil {
+		t.Error("hmac token must not verify against an rsa key")
+	}
+	if _, err := call(New(WithKey(secret), WithRequireExp(false)), noExp); err != nil {
+		t.Errorf("exp is optional with WithRequireExp(false): %v", err)
+	}
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if _, err := Sign("ES256", Claims{}, p384, ""); err != ErrInvalidKey {
+		t.Errorf("ES256 signed with a P-384 key: %v", err)
+	}
+	if err := verify("ES256", "a.b", make([]byte, 96), &p384.PublicKey); err != ErrInvalidKey {
+		t.Errorf("ES256 verified with a P-384 key: %v", err)
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	set := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   enc.EncodeToString(rsaKey.N.Bytes()),
+		"e":   enc.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}}
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(WithKeySet(NewFileKeySet(path, time.Hour)), WithAlgorithms("RS256"))
+	token, _ := Sign("RS256", Claims{"sub": "u", "exp": time.Now().Add(time.Hour).Unix()}, rsaKey, "k1")
+	if _, err := call(m, token); err != nil {
+		t.Fatal(err)
+	}
+	token, _ = Sign("RS256", Claims{"sub": "u", "exp": time.Now().Add(time.Hour).Unix()}, rsaKey, "k2")
+	if _, err := call(m, token); err == nil {
+		t.Fatal("unknown kid should be rejected")
+	}
+}
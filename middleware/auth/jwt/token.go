@@ -0,0 +1,249 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // register hashes
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("jwt: malformed token")
+	ErrUnsupportedAlg   = errors.New("jwt: unsupported algorithm")
+	ErrInvalidKey       = errors.New("jwt: key does not match algorithm")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrExpired          = errors.New("jwt: token is expired")
+	ErrNoExpiry         = errors.New("jwt: token has no exp")
+	ErrNotValidYet      = errors.New("jwt: token is not valid yet")
+	ErrIssuer           = errors.New("jwt: invalid issuer")
+	ErrAudience         = errors.New("jwt: invalid audience")
+)
+
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// curveBits is the curve size each ES algorithm is defined for.
+var curveBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+// Claims is the jwt payload.
+type Claims map[string]interface{}
+
+func (c Claims) String(key string) string {
+	s, _ := c[key].(string)
+	return s
+}
+
+// Strings reads a claim holding a string or a list of strings.
+func (c Claims) Strings(key string) []string {
+	switch v := c[key].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Time reads a NumericDate claim.
+func (c Claims) Time(key string) (time.Time, bool) {
+	switch v := c[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// Token is a parsed and verified jwt.
+type Token struct {
+	Header map[string]interface{}
+	Claims Claims
+	Raw    string
+}
+
+func (t *Token) Alg() string {
+	s, _ := t.Header["alg"].(string)
+	return s
+}
+
+func (t *Token) Kid() string {
+	s, _ := t.Header["kid"].(string)
+	return s
+}
+
+// KeyFunc returns the verification key of a token, the token signature is not checked yet.
+type KeyFunc func(t *Token) (interface{}, error)
+
+var enc = base64.RawURLEncoding
+
+// Parse decodes raw and verifies its signature with the key from keyFunc.
+// Claims are not validated.
+func Parse(raw string, keyFunc KeyFunc) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	t := &Token{Raw: raw}
+	if err := decodeSegment(parts[0], &t.Header); err != nil {
+		return nil, err
+	}
+	if err := decodeSegment(parts[1], &t.Claims); err != nil {
+		return nil, err
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if _, ok := hashes[t.Alg()]; !ok {
+		return nil, ErrUnsupportedAlg
+	}
+	key, err := keyFunc(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(t.Alg(), parts[0]+"."+parts[1], sig, key); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := enc.DecodeString(seg)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// Sign issues a token, key is []byte for HS, *rsa.PrivateKey for RS and
+// *ecdsa.PrivateKey for ES algorithms.
+func Sign(alg string, claims Claims, key interface{}, kid string) (string, error) {
+	hash, ok := hashes[alg]
+	if !ok {
+		return "", ErrUnsupportedAlg
+	}
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+
+	var sig []byte
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return "", ErrInvalidKey
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signing))
+		sig = mac.Sum(nil)
+	case "RS":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", ErrInvalidKey
+		}
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, priv, hash, digest(hash, signing)); err != nil {
+			return "", err
+		}
+	case "ES":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok || priv.Curve.Params().BitSize != curveBits[alg] {
+			return "", ErrInvalidKey
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest(hash, signing))
+		if err != nil {
+			return "", err
+		}
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+func verify(alg, signing string, sig []byte, key interface{}) error {
+	hash := hashes[alg]
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrInvalidKey
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signing))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidKey
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest(hash, signing), sig); err != nil {
+			return ErrInvalidSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != curveBits[alg] {
+			return ErrInvalidKey
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest(hash, signing), r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+	return nil
+}
+
+func digest(hash crypto.Hash, signing string) []byte {
+	h := hash.New()
+	h.Write([]byte(signing))
+	return h.Sum(nil)
+}
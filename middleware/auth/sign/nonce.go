@@ -0,0 +1,45 @@
+package sign
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers used nonces. Use must be atomic so a shared store
+// rejects a replay on every instance.
+type NonceStore interface {
+	// Use marks nonce as used for ttl and reports whether it was unused.
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+var _ NonceStore = (*MemoryNonceStore)(nil)
+
+type MemoryNonceStore struct {
+	lock      sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (m *MemoryNonceStore) Use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, exp := range m.nonces {
+			if now.After(exp) {
+				delete(m.nonces, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	if exp, ok := m.nonces[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,242 @@
+// Package sign verifies HMAC-SHA256 request signatures with timestamp and
+// nonce replay protection.
+//
+// The signed string joins with "\n": the method, the path (the full method
+// for grpc), the sorted query, the unix timestamp, the nonce and the hex
+// sha256 of the body. Grpc requests sign POST and the deterministic proto
+// encoding of the request message as the body.
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/transport"
+	"github.com/zander-84/gull/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"io"
+	http2 "net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderKey       = "X-Sign-Key"
+	HeaderTimestamp = "X-Sign-Timestamp"
+	HeaderNonce     = "X-Sign-Nonce"
+	HeaderSignature = "X-Sign-Signature"
+)
+
+var ErrKeyNotFound = errors.New("sign: key not found")
+
+// KeyStore returns the secret of a key id.
+type KeyStore interface {
+	Secret(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// StaticKeys is a KeyStore of fixed secrets.
+type StaticKeys map[string][]byte
+
+func (s StaticKeys) Secret(_ context.Context, keyID string) ([]byte, error) {
+	secret, ok := s[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return secret, nil
+}
+
+// Option is a sign option.
+type Option func(*options)
+
+type options struct {
+	keys    KeyStore
+	nonces  NonceStore
+	skew    time.Duration
+	maxBody int64
+	now     func() time.Time
+}
+
+// WithNonceStore sets where used nonces are kept, default NewMemoryNonceStore().
+func WithNonceStore(s NonceStore) Option {
+	return func(o *options) { o.nonces = s }
+}
+
+// WithSkew sets the accepted timestamp distance, default 5 minutes.
+func WithSkew(d time.Duration) Option {
+	return func(o *options) { o.skew = d }
+}
+
+// WithMaxBody limits the http body read for the digest, default 10MB.
+func WithMaxBody(n int64) Option {
+	return func(o *options) { o.maxBody = n }
+}
+
+// New returns a middleware verifying request signatures of keys.
+func New(keys KeyStore, opts ...Option) endpoint.Middleware {
+	o := options{
+		keys:    keys,
+		skew:    5 * time.Minute,
+		maxBody: 10 << 20,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.nonces == nil {
+		o.nonces = NewMemoryNonceStore()
+	}
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			keyID, err := o.verify(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			auth.SetPrincipal(ctx, &auth.Principal{ID: keyID, Type: "sign"})
+			return next(ctx, request)
+		}
+	}
+}
+
+func (o *options) verify(ctx context.Context, request interface{}) (string, error) {
+	keyID := auth.Header(ctx, HeaderKey)
+	ts := auth.Header(ctx, HeaderTimestamp)
+	nonce := auth.Header(ctx, HeaderNonce)
+	sig := auth.Header(ctx, HeaderSignature)
+	if keyID == "" || ts == "" || nonce == "" || sig == "" {
+		return "", auth.ErrSign("missing signature headers")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", auth.ErrSign("invalid timestamp")
+	}
+	if d := o.now().Sub(time.Unix(unix, 0)); d > o.skew || d < -o.skew {
+		return "", auth.ErrSign("timestamp out of range")
+	}
+
+	secret, err := o.keys.Secret(ctx, keyID)
+	if err != nil {
+		return "", auth.ErrUnauthorized(err.Error())
+	}
+
+	method, path, query, body := "POST", "", "", []byte(nil)
+	if c, ok := ctx.(http.Context); ok {
+		req := c.Request()
+		method, path, query = req.Method, req.URL.Path, req.URL.Query().Encode()
+		if body, err = readBody(req, o.maxBody); err != nil {
+			return "", auth.ErrSign(err.Error())
+		}
+	} else if tr, ok := transport.FromServerContext(ctx); ok {
+		path = tr.Operation()
+		if body, err = marshal(request); err != nil {
+			return "", auth.ErrSign(err.Error())
+		}
+	}
+
+	want := Signature(secret, method, path, query, ts, nonce, body)
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(sig))) {
+		return "", auth.ErrSign("signature mismatch")
+	}
+
+	// the nonce is only burnt for valid signatures so forged requests can not block it
+	fresh, err := o.nonces.Use(ctx, keyID+":"+nonce, 2*o.skew)
+	if err != nil {
+		return "", auth.ErrSign(err.Error())
+	}
+	if !fresh {
+		return "", auth.ErrSign("replayed nonce")
+	}
+	return keyID, nil
+}
+
+func readBody(req *http2.Request, max int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http2.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, max+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > max {
+		return nil, errors.New("body too large")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// marshal returns the body signed for a grpc request message.
+func marshal(msg interface{}) ([]byte, error) {
+	if msg == nil {
+		return nil, nil
+	}
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("request %T is not a proto message", msg)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// Signature returns the hex HMAC-SHA256 of the request fields.
+func Signature(secret []byte, method, path, query, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method), path, query, timestamp, nonce, hex.EncodeToString(sum[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SignRequest signs an outbound http request.
+func SignRequest(req *http2.Request, keyID string, secret []byte) error {
+	var body []byte
+	if req.Body != nil && req.Body != http2.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	ts, nonce := strconv.FormatInt(time.Now().Unix(), 10), newNonce()
+	req.Header.Set(HeaderKey, keyID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Signature(secret, req.Method, req.URL.Path, req.URL.Query().Encode(), ts, nonce, body))
+	return nil
+}
+
+// UnaryClientInterceptor signs outbound grpc calls.
+func UnaryClientInterceptor(keyID string, secret []byte) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		body, err := marshal(req)
+		if err != nil {
+			return err
+		}
+		ts, nonce := strconv.FormatInt(time.Now().Unix(), 10), newNonce()
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			strings.ToLower(HeaderKey), keyID,
+			strings.ToLower(HeaderTimestamp), ts,
+			strings.ToLower(HeaderNonce), nonce,
+			strings.ToLower(HeaderSignature), Signature(secret, "POST", method, "", ts, nonce, body),
+		)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,93 @@
+package sign
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/grpc"
+	"github.com/zander-84/gull/transport/http"
+	grpc2 "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	http2 "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var keys = StaticKeys{"app": []byte("secret")}
+
+func handler(ctx context.Context, request interface{}) (interface{}, error) {
+	p, _ := auth.FromContext(ctx)
+	return p, nil
+}
+
+func callHTTP(h endpoint.HandlerFunc, req *http2.Request) (interface{}, error) {
+	req = req.WithContext(endpoint.WithContext(req.Context(), nil))
+	return h(http.NewHttpContext(httptest.NewRecorder(), req), nil)
+}
+
+func TestHTTP(t *testing.T) {
+	h := New(keys)(handler)
+
+	req := httptest.NewRequest(http2.MethodPost, "/orders?b=2&a=1", strings.NewReader(`{"id":1}`))
+	if err := SignRequest(req, "app", keys["app"]); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := callHTTP(h, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := resp.(*auth.Principal); p.ID != "app" {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	replay := httptest.NewRequest(http2.MethodPost, "/orders?b=2&a=1", strings.NewReader(`{"id":1}`))
+	replay.Header = req.Header.Clone()
+	if _, err := callHTTP(h, replay); think.GetCode(err) != think.CodeSignError {
+		t.Fatalf("replay: code = %v, want CodeSignError", think.GetCode(err))
+	}
+
+	tampered := httptest.NewRequest(http2.MethodPost, "/orders?b=2&a=1", strings.NewReader(`{"id":2}`))
+	tampered.Header = req.Header.Clone()
+	tampered.Header.Set(HeaderNonce, "fresh")
+	if _, err := callHTTP(h, tampered); think.GetCode(err) != think.CodeSignError {
+		t.Fatalf("tampered: code = %v, want CodeSignError", think.GetCode(err))
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	h := New(keys)(handler)
+
+	var out metadata.MD
+	err := UnaryClientInterceptor("app", keys["app"])(context.Background(), "/pkg.Svc/Call", wrapperspb.String("a"), nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc2.ClientConn, opts ...grpc2.CallOption) error {
+			out, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(request interface{}) error {
+		ctx := metadata.NewIncomingContext(context.Background(), out)
+		ctx = grpc2.NewContextWithServerTransportStream(ctx, &stream{method: "/pkg.Svc/Call"})
+		ctx = endpoint.WithContext(ctx, nil)
+		_, err := h(grpc.NewGrpcContext(ctx), request)
+		return err
+	}
+	if err := call(wrapperspb.String("b")); !think.IsErrSign(err) {
+		t.Fatalf("tampered message: code = %v, want CodeSignError", think.GetCode(err))
+	}
+	if err := call(wrapperspb.String("a")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type stream struct {
+	grpc2.ServerTransportStream
+	method string
+}
+
+func (s *stream) Method() string { return s.method }
@@ -0,0 +1,190 @@
+// Package authz authorizes authenticated principals against the
+// permissions endpoints declare with OptionsPermission.
+package authz
+
+import (
+	"context"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/think"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const permissionKey = "authz.permissions"
+
+// OptionsPermission declares permissions an endpoint or group requires,
+// group and endpoint permissions add up.
+func OptionsPermission(perms ...string) endpoint.Options {
+	return func(c *endpoint.Conf) {
+		prev, _ := c.Values[permissionKey].([]string)
+		all := make([]string, 0, len(prev)+len(perms))
+		all = append(append(all, prev...), perms...)
+		endpoint.OptionsValue(permissionKey, all)(c)
+	}
+}
+
+// AttrFunc is a programmatic attribute rule for a permission.
+type AttrFunc func(ctx context.Context, p *auth.Principal) bool
+
+// Engine evaluates a policy, it is safe to Update while serving.
+type Engine struct {
+	policy atomic.Value // *compiled
+	lock   sync.RWMutex
+	attrs  map[string][]AttrFunc
+}
+
+func NewEngine(p *Policy) (*Engine, error) {
+	e := &Engine{attrs: make(map[string][]AttrFunc)}
+	if err := e.Update(p); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Update swaps the policy, the old one is kept when p is invalid.
+func (e *Engine) Update(p *Policy) error {
+	c, err := compile(p)
+	if err != nil {
+		return err
+	}
+	e.policy.Store(c)
+	return nil
+}
+
+// AddAttr adds an attribute rule every holder of perm must also pass.
+func (e *Engine) AddAttr(perm string, f AttrFunc) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.attrs[perm] = append(e.attrs[perm], f)
+}
+
+// Allow reports whether p holds perm and passes its attribute rules.
+func (e *Engine) Allow(ctx context.Context, p *auth.Principal, perm string) bool {
+	c := e.policy.Load().(*compiled)
+	granted := false
+	for _, role := range p.Roles {
+		for _, g := range c.perms[role] {
+			if match(g, perm) {
+				granted = true
+				break
+			}
+		}
+		if granted {
+			break
+		}
+	}
+	if !granted {
+		return false
+	}
+
+	for _, r := range c.rules {
+		if match(r.Permission, perm) && !claimIn(p.Claims[r.Claim], r.In) {
+			return false
+		}
+	}
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	for _, f := range e.attrs[perm] {
+		if !f(ctx, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func claimIn(claim interface{}, in []string) bool {
+	var values []interface{}
+	switch c := claim.(type) {
+	case []interface{}:
+		values = c
+	case []string:
+		values = make([]interface{}, len(c))
+		for i, v := range c {
+			values[i] = v
+		}
+	default:
+		values = []interface{}{claim}
+	}
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		s := fmt.Sprint(v)
+		for _, want := range in {
+			if s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Watch loads the policy file, then polls it and reloads it on change, it
+// blocks until ctx is done.
+func (e *Engine) Watch(ctx context.Context, path string, interval time.Duration) {
+	var modTime time.Time
+	var size int64 = -1
+	reload := func() {
+		fi, err := os.Stat(path)
+		if err != nil || (fi.ModTime().Equal(modTime) && fi.Size() == size) {
+			return
+		}
+		modTime, size = fi.ModTime(), fi.Size()
+		p, err := LoadFile(path)
+		if err == nil {
+			err = e.Update(p)
+		}
+		if err != nil {
+			log.Printf("[Authz] reload %s err: %v", path, err)
+			return
+		}
+		log.Printf("[Authz] reloaded %s", path)
+	}
+
+	reload()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload()
+		}
+	}
+}
+
+// New returns a middleware denying principals missing a required permission
+// with think.CodeForbidden.
+func New(e *Engine) endpoint.Middleware {
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			v, ok := endpoint.GetCtxVal(ctx)
+			if !ok {
+				return next(ctx, request)
+			}
+			perms, _ := v.Value(permissionKey)
+			required, _ := perms.([]string)
+			if len(required) == 0 {
+				return next(ctx, request)
+			}
+
+			p, ok := auth.FromContext(ctx)
+			if !ok {
+				return nil, auth.ErrUnauthorized("unauthenticated")
+			}
+			for _, perm := range required {
+				if !e.Allow(ctx, p, perm) {
+					return nil, think.New(think.CodeForbidden, "", think.CodeForbidden.ToString(), "missing permission "+perm)
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
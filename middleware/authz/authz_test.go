@@ -0,0 +1,169 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/middleware/auth"
+	"github.com/zander-84/gull/middleware/auth/apikey"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/grpc"
+	"google.golang.org/grpc/metadata"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var policy = &Policy{
+	Roles: map[string]Role{
+		"admin":  {Inherits: []string{"editor"}, Permissions: []string{"user:*"}},
+		"editor": {Inherits: []string{"viewer"}, Permissions: []string{"post:write"}},
+		"viewer": {Permissions: []string{"post:read"}},
+	},
+	Rules: []Rule{{Permission: "post:write", Claim: "tenant", In: []string{"cn"}}},
+}
+
+func TestEngine(t *testing.T) {
+	e, err := NewEngine(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &auth.Principal{Roles: []string{"admin"}, Claims: map[string]interface{}{"tenant": "cn"}}
+	viewer := &auth.Principal{Roles: []string{"viewer"}}
+
+	tests := []struct {
+		p    *auth.Principal
+		perm string
+		want bool
+	}{
+		{admin, "post:read", true},
+		{admin, "user:delete", true},
+		{admin, "post:write", true},
+		{viewer, "post:read", true},
+		{viewer, "post:write", false},
+		{&auth.Principal{Roles: []string{"editor"}, Claims: map[string]interface{}{"tenant": "us"}}, "post:write", false},
+	}
+	for _, tt := range tests {
+		if got := e.Allow(context.Background(), tt.p, tt.perm); got != tt.want {
+			t.Errorf("Allow(%v, %s) = %v, want %v", tt.p.Roles, tt.perm, got, tt.want)
+		}
+	}
+
+	if _, err := NewEngine(&Policy{Roles: map[string]Role{"a": {Inherits: []string{"a"}}}}); err == nil {
+		t.Error("cyclic roles should be rejected")
+	}
+	if _, err := NewEngine(nil); err == nil {
+		t.Error("a nil policy should be rejected")
+	}
+}
+
+func TestAPIKeyPrincipal(t *testing.T) {
+	e, _ := NewEngine(&Policy{
+		Roles: map[string]Role{"reader": {Permissions: []string{"report:*"}}},
+		Rules: []Rule{{Permission: "report:export", Claim: "scope", In: []string{"export"}}},
+	})
+	store := apikey.NewMemoryStore()
+	store.Add("k1", &auth.Principal{ID: "etl", Roles: []string{"reader"}, Claims: map[string]interface{}{"scope": []string{"read", "export"}}})
+	store.Add("k2", &auth.Principal{ID: "bi", Roles: []string{"reader"}, Claims: map[string]interface{}{"scope": []string{"read"}}})
+
+	r := endpoint.NewRmc().Group("/reports", endpoint.OptionsMiddleware(apikey.New(store), New(e)))
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodGet, "/export", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	}, nil, nil, OptionsPermission("report:export"))
+
+	call := func(key string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", key))
+		_, err := r.MustGetEndpoint(endpoint.MethodGet, "/export")(grpc.NewGrpcContext(endpoint.WithContext(ctx, nil)), nil)
+		return err
+	}
+	if err := call("k1"); err != nil {
+		t.Fatalf("a []string claim holding the scope should pass: %v", err)
+	}
+	if err := call("k2"); think.GetCode(err) != think.CodeForbidden {
+		t.Fatalf("code = %v, want CodeForbidden", think.GetCode(err))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	e, _ := NewEngine(policy)
+	var principal *auth.Principal
+	login := func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if principal != nil {
+				auth.SetPrincipal(ctx, principal)
+			}
+			return next(ctx, request)
+		}
+	}
+	ok := func(ctx context.Context, request interface{}) (interface{}, error) { return "ok", nil }
+
+	r := endpoint.NewRmc().Group("/posts", endpoint.OptionsMiddleware(login, New(e)), OptionsPermission("post:read"))
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodGet, "/list", ok, nil, nil)
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodPost, "/edit", ok, nil, nil, OptionsPermission("post:write"))
+
+	call := func(method endpoint.Method, path string) error {
+		_, err := r.MustGetEndpoint(method, path)(endpoint.WithContext(context.Background(), nil), nil)
+		return err
+	}
+
+	if err := call(endpoint.MethodGet, "/list"); think.GetCode(err) != think.CodeUnauthorized {
+		t.Fatalf("code = %v, want CodeUnauthorized", think.GetCode(err))
+	}
+	principal = &auth.Principal{Roles: []string{"viewer"}}
+	if err := call(endpoint.MethodGet, "/list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(endpoint.MethodPost, "/edit"); think.GetCode(err) != think.CodeForbidden {
+		t.Fatalf("code = %v, want CodeForbidden", think.GetCode(err))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	write := func(p *Policy) {
+		data, _ := json.Marshal(p)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(&Policy{Roles: map[string]Role{"viewer": {Permissions: []string{"post:read"}}}})
+
+	p, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := NewEngine(p)
+	viewer := &auth.Principal{Roles: []string{"viewer"}}
+
+	// the file is loaded before the first tick
+	write(&Policy{Roles: map[string]Role{"viewer": {Permissions: []string{"post:read", "post:share"}}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Watch(ctx, path, time.Hour)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for !e.Allow(ctx, viewer, "post:share") {
+		if time.Now().After(deadline) {
+			t.Fatal("policy was not loaded before the first tick")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go e.Watch(ctx, path, 10*time.Millisecond)
+	write(&Policy{Roles: map[string]Role{"viewer": {Permissions: []string{"post:read", "post:comment"}}}})
+	deadline = time.Now().Add(time.Second)
+	for !e.Allow(ctx, viewer, "post:comment") {
+		if time.Now().After(deadline) {
+			t.Fatal("policy was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
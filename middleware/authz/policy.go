@@ -0,0 +1,106 @@
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Policy is the declarative RBAC configuration, usually loaded from json:
+//
+//	{
+//	  "roles": {
+//	    "admin":  {"inherits": ["editor"], "permissions": ["user:*"]},
+//	    "editor": {"inherits": ["viewer"], "permissions": ["post:write"]},
+//	    "viewer": {"permissions": ["post:read"]}
+//	  },
+//	  "rules": [
+//	    {"permission": "post:write", "claim": "tenant", "in": ["cn", "us"]}
+//	  ]
+//	}
+type Policy struct {
+	Roles map[string]Role `json:"roles"`
+	Rules []Rule          `json:"rules"`
+}
+
+// Role grants permissions, plus those of the roles it inherits.
+type Role struct {
+	Inherits    []string `json:"inherits"`
+	Permissions []string `json:"permissions"`
+}
+
+// Rule is an attribute rule: a principal holding Permission must also have
+// the Claim set to one of In.
+type Rule struct {
+	Permission string   `json:"permission"`
+	Claim      string   `json:"claim"`
+	In         []string `json:"in"`
+}
+
+// LoadFile reads a json policy.
+func LoadFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := new(Policy)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("authz: parse %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// compiled is a policy with the role hierarchy flattened.
+type compiled struct {
+	perms map[string][]string // role -> all permissions
+	rules []Rule
+}
+
+func compile(p *Policy) (*compiled, error) {
+	if p == nil {
+		return nil, errors.New("authz: nil policy")
+	}
+	c := &compiled{perms: make(map[string][]string, len(p.Roles)), rules: p.Rules}
+	for name := range p.Roles {
+		seen := make(map[string]bool)
+		perms, err := expand(p, name, seen, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.perms[name] = perms
+	}
+	return c, nil
+}
+
+func expand(p *Policy, name string, visiting map[string]bool, out []string) ([]string, error) {
+	if visiting[name] {
+		return nil, fmt.Errorf("authz: role %q inherits itself", name)
+	}
+	role, ok := p.Roles[name]
+	if !ok {
+		return nil, fmt.Errorf("authz: unknown role %q", name)
+	}
+	visiting[name] = true
+	out = append(out, role.Permissions...)
+	for _, parent := range role.Inherits {
+		var err error
+		if out, err = expand(p, parent, visiting, out); err != nil {
+			return nil, err
+		}
+	}
+	delete(visiting, name)
+	return out, nil
+}
+
+// match reports whether granted covers perm, "*" and "post:*" are wildcards.
+func match(granted, perm string) bool {
+	if granted == "*" || granted == perm {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(perm, granted[:len(granted)-1])
+	}
+	return false
+}
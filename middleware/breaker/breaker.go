@@ -0,0 +1,327 @@
+// Package breaker provides a circuit breaker for endpoints and outbound calls.
+package breaker
+
+import (
+	"context"
+	"github.com/zander-84/gull/think"
+	"sync"
+	"time"
+)
+
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrOpen is returned while the breaker rejects calls.
+var ErrOpen = think.New(think.CodeUnavailable, "", think.CodeUnavailable.ToString(), "circuit breaker is open")
+
+// errPanicked is recorded for a call that panicked.
+var errPanicked = think.ErrPanic("call panicked")
+
+// Option is a breaker option.
+type Option func(*options)
+
+type options struct {
+	window        time.Duration
+	buckets       int
+	minRequests   int64
+	errorRatio    float64
+	slowCall      time.Duration
+	slowRatio     float64
+	openTimeout   time.Duration
+	halfOpenCalls int64
+	isFailure     func(err error) bool
+	onStateChange func(from, to State)
+}
+
+// WithWindow sets the rolling statistic window and its bucket count, default 10s and 10 buckets.
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(o *options) {
+		o.window = window
+		o.buckets = buckets
+	}
+}
+
+// WithMinRequests sets the calls needed in the window before the breaker may trip, default 20.
+func WithMinRequests(n int64) Option {
+	return func(o *options) { o.minRequests = n }
+}
+
+// WithErrorRatio trips the breaker when failures/calls reaches ratio, default 0.5.
+func WithErrorRatio(ratio float64) Option {
+	return func(o *options) { o.errorRatio = ratio }
+}
+
+// WithSlowCall trips the breaker when calls slower than d reach ratio, disabled by default.
+func WithSlowCall(d time.Duration, ratio float64) Option {
+	return func(o *options) {
+		o.slowCall = d
+		o.slowRatio = ratio
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before probing, default 5s.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(o *options) { o.openTimeout = d }
+}
+
+// WithHalfOpenCalls sets the probes that must succeed to close the breaker, default 3.
+func WithHalfOpenCalls(n int64) Option {
+	return func(o *options) { o.halfOpenCalls = n }
+}
+
+// WithFailure decides which errors count as failures, default server side think codes.
+func WithFailure(f func(err error) bool) Option {
+	return func(o *options) { o.isFailure = f }
+}
+
+// WithStateChange is called on every transition.
+func WithStateChange(f func(from, to State)) Option {
+	return func(o *options) { o.onStateChange = f }
+}
+
+// IsServerFault reports errors whose think code maps to a 5xx status, see
+// think.IsServerFault.
+func IsServerFault(err error) bool {
+	return think.IsServerFault(err)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		window:        10 * time.Second,
+		buckets:       10,
+		minRequests:   20,
+		errorRatio:    0.5,
+		openTimeout:   5 * time.Second,
+		halfOpenCalls: 3,
+		isFailure:     IsServerFault,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.buckets < 1 {
+		o.buckets = 1
+	}
+	return o
+}
+
+type bucket struct {
+	start    time.Time
+	total    int64
+	failures int64
+	slow     int64
+}
+
+// Breaker is a closed/open/half-open circuit breaker.
+type Breaker struct {
+	opts options
+	lock sync.Mutex
+
+	state    State
+	gen      uint64 // bumped on every transition
+	openedAt time.Time
+	buckets  []bucket
+	size     time.Duration
+
+	probes    int64 // half-open calls in flight
+	successes int64 // half-open calls succeeded
+}
+
+func NewBreaker(opts ...Option) *Breaker {
+	o := newOptions(opts)
+	return &Breaker{
+		opts:    o,
+		buckets: make([]bucket, o.buckets),
+		size:    o.window / time.Duration(o.buckets),
+	}
+}
+
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Record with the returned generation.
+func (b *Breaker) Allow() (uint64, error) {
+	b.lock.Lock()
+	from := b.state
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.opts.openTimeout {
+		b.toHalfOpen()
+	}
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = ErrOpen
+	case StateHalfOpen:
+		if b.probes+b.successes >= b.opts.halfOpenCalls {
+			err = ErrOpen
+		} else {
+			b.probes++
+		}
+	}
+	to, gen := b.state, b.gen
+	b.lock.Unlock()
+
+	b.notify(from, to)
+	return gen, err
+}
+
+// Record reports the outcome of a call allowed in generation gen. Outcomes
+// of calls allowed before the last transition are ignored, they tell
+// nothing about the current state and hold no half-open probe.
+func (b *Breaker) Record(gen uint64, err error, took time.Duration) {
+	failed := b.opts.isFailure(err)
+	slow := b.opts.slowCall > 0 && took >= b.opts.slowCall
+
+	b.lock.Lock()
+	if gen != b.gen {
+		b.lock.Unlock()
+		return
+	}
+	from := b.state
+	switch b.state {
+	case StateHalfOpen:
+		b.probes--
+		if failed || slow {
+			b.toOpen()
+			break
+		}
+		b.successes++
+		if b.successes >= b.opts.halfOpenCalls {
+			b.toClosed()
+		}
+	case StateClosed:
+		bk := b.current(time.Now())
+		bk.total++
+		if failed {
+			bk.failures++
+		}
+		if slow {
+			bk.slow++
+		}
+		if b.shouldTrip(time.Now()) {
+			b.toOpen()
+		}
+	}
+	to := b.state
+	b.lock.Unlock()
+
+	b.notify(from, to)
+}
+
+// Do runs fn if the breaker allows it and records the result.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	gen, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	return b.call(gen, func() error { return fn(ctx) })
+}
+
+// call runs fn and records its result. A panic, recovered further up the
+// stack, still counts as a failure and releases its half-open probe; it is
+// left to unwind untouched so its stack is not lost.
+func (b *Breaker) call(gen uint64, fn func() error) error {
+	start := time.Now()
+	panicked := true
+	defer func() {
+		if panicked {
+			b.Record(gen, errPanicked, time.Since(start))
+		}
+	}()
+	err := fn()
+	panicked = false
+	b.Record(gen, err, time.Since(start))
+	return err
+}
+
+func (b *Breaker) current(now time.Time) *bucket {
+	start := now.Truncate(b.size)
+	idx := int(start.UnixNano()/int64(b.size)) % len(b.buckets)
+	bk := &b.buckets[idx]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	var total, failures, slow int64
+	for _, bk := range b.buckets {
+		if now.Sub(bk.start) >= b.opts.window {
+			continue
+		}
+		total += bk.total
+		failures += bk.failures
+		slow += bk.slow
+	}
+	if total == 0 || total < b.opts.minRequests {
+		return false
+	}
+	if float64(failures)/float64(total) >= b.opts.errorRatio {
+		return true
+	}
+	return b.opts.slowCall > 0 && float64(slow)/float64(total) >= b.opts.slowRatio
+}
+
+func (b *Breaker) toOpen() {
+	b.gen++
+	b.state = StateOpen
+	b.openedAt = time.Now()
+	b.probes, b.successes = 0, 0
+	time.AfterFunc(b.opts.openTimeout, b.probe)
+}
+
+func (b *Breaker) toHalfOpen() {
+	b.gen++
+	b.state = StateHalfOpen
+	b.probes, b.successes = 0, 0
+}
+
+func (b *Breaker) toClosed() {
+	b.gen++
+	b.state = StateClosed
+	b.probes, b.successes = 0, 0
+	for i := range b.buckets {
+		b.buckets[i] = bucket{}
+	}
+}
+
+// probe moves an open breaker to half-open once the open timeout passed, so
+// nodes taken out of rotation get traffic again without waiting for a call.
+func (b *Breaker) probe() {
+	b.lock.Lock()
+	from := b.state
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.opts.openTimeout {
+		b.toHalfOpen()
+	}
+	to := b.state
+	b.lock.Unlock()
+
+	b.notify(from, to)
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.opts.onStateChange != nil {
+		b.opts.onStateChange(from, to)
+	}
+}
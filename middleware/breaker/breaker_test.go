@@ -0,0 +1,132 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"github.com/zander-84/gull/contrib/lb"
+	"github.com/zander-84/gull/think"
+	"testing"
+	"time"
+)
+
+var errBoom = errors.New("boom")
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker(WithMinRequests(4), WithErrorRatio(0.5), WithOpenTimeout(50*time.Millisecond), WithHalfOpenCalls(2))
+	fail := func(context.Context) error { return errBoom }
+	ok := func(context.Context) error { return nil }
+
+	_ = b.Do(context.Background(), ok)
+	_ = b.Do(context.Background(), ok)
+	_ = b.Do(context.Background(), fail)
+	if b.State() != StateClosed {
+		t.Fatal("breaker should stay closed below min requests")
+	}
+	_ = b.Do(context.Background(), fail)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %v, want open", b.State())
+	}
+	if err := b.Do(context.Background(), ok); think.GetCode(err) != think.CodeUnavailable {
+		t.Fatalf("open breaker returned %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %v, want half-open", b.State())
+	}
+	_ = b.Do(context.Background(), ok)
+	_ = b.Do(context.Background(), ok)
+	if b.State() != StateClosed {
+		t.Fatalf("state = %v, want closed", b.State())
+	}
+}
+
+func TestHalfOpenPanic(t *testing.T) {
+	b := NewBreaker(WithMinRequests(1), WithOpenTimeout(10*time.Millisecond), WithHalfOpenCalls(1))
+	_ = b.Do(context.Background(), func(context.Context) error { return errBoom })
+	time.Sleep(20 * time.Millisecond)
+
+	h := New()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, b.Do(ctx, func(context.Context) error { panic("probe") })
+	})
+	func() {
+		defer func() {
+			if recover() != "probe" {
+				t.Fatal("the panic should go on up the stack")
+			}
+		}()
+		_, _ = h(context.Background(), nil)
+	}()
+	if b.State() != StateOpen {
+		t.Fatalf("state = %v, a panicking probe should reopen the breaker", b.State())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Do(context.Background(), func(context.Context) error { return nil }); err != nil || b.State() != StateClosed {
+		t.Fatalf("err = %v, state = %v, the probe should have been released", err, b.State())
+	}
+}
+
+func TestStaleRecord(t *testing.T) {
+	b := NewBreaker(WithMinRequests(1), WithOpenTimeout(10*time.Millisecond), WithHalfOpenCalls(1))
+	stale, _ := b.Allow()
+	_ = b.Do(context.Background(), func(context.Context) error { return errBoom })
+	time.Sleep(20 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %v, want half-open", b.State())
+	}
+
+	b.Record(stale, nil, 0)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %v, a call allowed while closed should not close the breaker", b.State())
+	}
+	gen, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("a stale record should not release a probe")
+	}
+	b.Record(gen, nil, 0)
+	if b.State() != StateClosed {
+		t.Fatalf("state = %v, want closed", b.State())
+	}
+}
+
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	b := NewBreaker(WithMinRequests(1))
+	_ = b.Do(context.Background(), func(context.Context) error {
+		return think.New(think.CodeParamError, "", "", "")
+	})
+	if b.State() != StateClosed {
+		t.Fatal("client faults should not trip the breaker")
+	}
+}
+
+func TestSlowCall(t *testing.T) {
+	b := NewBreaker(WithMinRequests(1), WithSlowCall(time.Millisecond, 1))
+	gen, _ := b.Allow()
+	b.Record(gen, nil, 10*time.Millisecond)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %v, want open", b.State())
+	}
+}
+
+func TestNodes(t *testing.T) {
+	l := lb.NewListener("test")
+	_ = l.Set(map[any]int{"a": 1, "b": 1})
+	nodes := NewNodes(l, WithMinRequests(1), WithOpenTimeout(50*time.Millisecond))
+
+	_ = nodes.Do(context.Background(), "a", func(context.Context) error { return errBoom })
+	if _, all, _ := l.Get(); len(all) != 1 || all[0] != "b" {
+		t.Fatalf("tripped node should leave rotation, got %v", all)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, all, _ := l.Get(); len(all) != 2 {
+		t.Fatalf("half-open node should be back in rotation, got %v", all)
+	}
+	if l.Err() != nil {
+		t.Fatal("node errors must not fail the whole listener")
+	}
+}
@@ -0,0 +1,86 @@
+package breaker
+
+import (
+	"context"
+	"github.com/zander-84/gull/contrib/lb"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/internal/group"
+	"sync"
+)
+
+// New returns a middleware keeping one breaker per route.
+func New(opts ...Option) endpoint.Middleware {
+	breakers := group.NewGroup(func() interface{} {
+		return NewBreaker(opts...)
+	})
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (resp interface{}, err error) {
+			key := ""
+			if v, ok := endpoint.GetCtxVal(ctx); ok {
+				key = endpoint.Key(v.GetMethod(), v.GetPath())
+			}
+			b := breakers.Get(key).(*Breaker)
+			gen, err := b.Allow()
+			if err != nil {
+				return nil, err
+			}
+			err = b.call(gen, func() error {
+				resp, err = next(ctx, request)
+				return err
+			})
+			return resp, err
+		}
+	}
+}
+
+// Nodes keeps one breaker per balancer node and takes tripped nodes out of
+// rotation through Listener.RecordErr.
+type Nodes struct {
+	listener lb.Listener
+	opts     []Option
+	lock     sync.RWMutex
+	breakers map[any]*Breaker
+}
+
+func NewNodes(listener lb.Listener, opts ...Option) *Nodes {
+	return &Nodes{
+		listener: listener,
+		opts:     opts,
+		breakers: make(map[any]*Breaker),
+	}
+}
+
+// Breaker returns the breaker of node, node must be the key given to the listener.
+func (n *Nodes) Breaker(node any) *Breaker {
+	n.lock.RLock()
+	b, ok := n.breakers[node]
+	n.lock.RUnlock()
+	if ok {
+		return b
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if b, ok = n.breakers[node]; ok {
+		return b
+	}
+	o := newOptions(n.opts)
+	opts := append(append([]Option{}, n.opts...), WithStateChange(func(from, to State) {
+		if to == StateOpen {
+			n.listener.RecordErr(&lb.NodeError{Node: node, Err: ErrOpen})
+		} else if from == StateOpen {
+			n.listener.RecordErr(&lb.NodeError{Node: node})
+		}
+		if o.onStateChange != nil {
+			o.onStateChange(from, to)
+		}
+	}))
+	b = NewBreaker(opts...)
+	n.breakers[node] = b
+	return b
+}
+
+// Do runs fn against node through its breaker.
+func (n *Nodes) Do(ctx context.Context, node any, fn func(ctx context.Context) error) error {
+	return n.Breaker(node).Do(ctx, fn)
+}
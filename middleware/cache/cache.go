@@ -0,0 +1,385 @@
+// Package cache caches endpoint responses of http GET and HEAD requests.
+//
+// Entries are keyed by method, path, query, the configured vary headers and
+// the authenticated principal, and carry an ETag, so clients revalidating with If-None-Match get a 304
+// from the transport. Handlers label responses with Tag and drop them from
+// any endpoint with Invalidate. Requests with credentials but no principal
+// yet, as when the cache runs before authentication, are not cached unless
+// WithShared is set.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/transport/http"
+	"golang.org/x/sync/singleflight"
+	"log"
+	http2 "net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderCache = "X-Cache"
+
+	tagsKey       = "cache.tags"
+	invalidateKey = "cache.invalidate"
+)
+
+// Tag labels the response of the running request, see Cache.Invalidate.
+func Tag(ctx context.Context, tags ...string) {
+	appendCtx(ctx, tagsKey, tags)
+}
+
+// Invalidate drops the entries tagged with tags once the running request
+// succeeds, typically called from the handlers of writes.
+func Invalidate(ctx context.Context, tags ...string) {
+	appendCtx(ctx, invalidateKey, tags)
+}
+
+func appendCtx(ctx context.Context, key string, tags []string) {
+	v, ok := endpoint.GetCtxVal(ctx)
+	if !ok {
+		return
+	}
+	prev, _ := v.Get(key)
+	old, _ := prev.([]string)
+	v.Set(key, append(append([]string{}, old...), tags...))
+}
+
+func ctxTags(v *endpoint.CtxVal, key string) []string {
+	tags, _ := v.Get(key)
+	out, _ := tags.([]string)
+	return out
+}
+
+// Option is a cache option.
+type Option func(*options)
+
+type options struct {
+	ttl        time.Duration
+	stale      time.Duration
+	vary       []string
+	maxEntries int
+	shared     bool
+}
+
+// WithTTL sets how long a response is served as fresh, default 1 minute.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) { o.ttl = d }
+}
+
+// WithStaleWhileRevalidate serves expired responses for up to d more while
+// a single background request refreshes them.
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(o *options) { o.stale = d }
+}
+
+// WithVaryHeaders adds request headers to the cache key, like Accept-Language.
+func WithVaryHeaders(headers ...string) Option {
+	return func(o *options) {
+		for _, h := range headers {
+			o.vary = append(o.vary, http2.CanonicalHeaderKey(h))
+		}
+	}
+}
+
+// WithShared shares responses between callers, for endpoints whose responses
+// do not depend on who asks, even with credentials.
+func WithShared(shared bool) Option {
+	return func(o *options) { o.shared = shared }
+}
+
+// WithMaxEntries bounds the cache, least recently used entries are evicted first, default 10000.
+func WithMaxEntries(n int) Option {
+	return func(o *options) { o.maxEntries = n }
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	etag    string
+	freshAt time.Time
+	staleAt time.Time
+	tags    []string
+}
+
+type Cache struct {
+	opts  options
+	group singleflight.Group
+
+	lock       sync.Mutex
+	lru        *list.List               // of *entry, front is most recent
+	entries    map[string]*list.Element // key -> lru element
+	tags       map[string]map[string]struct{}
+	refreshing map[string]bool
+}
+
+func New(opts ...Option) *Cache {
+	o := options{
+		ttl:        time.Minute,
+		maxEntries: 10000,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Cache{
+		opts:       o,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+		refreshing: make(map[string]bool),
+	}
+}
+
+// Invalidate drops every entry tagged with one of tags.
+func (c *Cache) Invalidate(tags ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if el, ok := c.entries[key]; ok {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) get(key string) (*entry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.staleAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+func (c *Cache) set(e *entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	for _, tag := range e.tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[e.key] = struct{}{}
+	}
+	for c.opts.maxEntries > 0 && c.lru.Len() > c.opts.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// Middleware caches GET and HEAD responses and applies the invalidations
+// requested by handlers of any method.
+func (c *Cache) Middleware() endpoint.Middleware {
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			hc, ok := ctx.(http.Context)
+			ctxVal, hasVal := endpoint.GetCtxVal(ctx)
+			if !ok || !hasVal {
+				return next(ctx, request)
+			}
+			req := hc.Request()
+			if req.Method != http2.MethodGet && req.Method != http2.MethodHead {
+				resp, err := next(ctx, request)
+				if err == nil {
+					c.Invalidate(ctxTags(ctxVal, invalidateKey)...)
+				}
+				return resp, err
+			}
+
+			key, ok := c.key(req, ctxVal)
+			if !ok {
+				return next(ctx, request)
+			}
+			if e, ok := c.get(key); ok {
+				status := "HIT"
+				if time.Now().After(e.freshAt) {
+					status = "STALE"
+					c.revalidate(key, hc, ctxVal, request, next)
+				}
+				c.reply(hc, e, status)
+				return e.value, nil
+			}
+
+			// the call is shared by every waiting request, so the one starting
+			// it going away must not cancel it
+			fillCtx := http.NewHttpContext(hc.Response(), req.WithContext(detached{req.Context()}))
+			filled := false
+			v, err, _ := c.group.Do(key, func() (interface{}, error) {
+				filled = true
+				return c.fill(fillCtx, ctxVal, key, request, next)
+			})
+			if err != nil {
+				return nil, err
+			}
+			e := v.(*entry)
+			status := "HIT"
+			if filled {
+				status = "MISS"
+			}
+			c.reply(hc, e, status)
+			return e.value, nil
+		}
+	}
+}
+
+// fill runs the handler and stores its response, errors are not cached.
+func (c *Cache) fill(ctx context.Context, ctxVal *endpoint.CtxVal, key string, request interface{}, next endpoint.HandlerFunc) (*entry, error) {
+	resp, err := next(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	c.Invalidate(ctxTags(ctxVal, invalidateKey)...)
+
+	e := &entry{key: key, value: resp, tags: ctxTags(ctxVal, tagsKey)}
+	if data, mErr := json.Marshal(resp); mErr == nil {
+		sum := sha1.Sum(data)
+		e.etag = `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+	now := time.Now()
+	e.freshAt = now.Add(c.opts.ttl)
+	e.staleAt = e.freshAt.Add(c.opts.stale)
+	c.set(e)
+	return e, nil
+}
+
+// revalidate refreshes key in the background, one refresh per key at a time.
+func (c *Cache) revalidate(key string, hc http.Context, ctxVal *endpoint.CtxVal, request interface{}, next endpoint.HandlerFunc) {
+	c.lock.Lock()
+	if c.refreshing[key] {
+		c.lock.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.lock.Unlock()
+
+	val := ctxVal.Clone()
+	val.Set(tagsKey, nil)
+	val.Set(invalidateKey, nil)
+	req := hc.Request().Clone(endpoint.WithContext(context.Background(), val))
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[Cache] revalidate %s panic: %v", key, r)
+			}
+			c.lock.Lock()
+			delete(c.refreshing, key)
+			c.lock.Unlock()
+		}()
+		_, err := c.fill(http.NewHttpContext(discardWriter{header: make(http2.Header)}, req), val, key, request, next)
+		if err != nil {
+			log.Printf("[Cache] revalidate %s err: %v", key, err)
+		}
+	}()
+}
+
+func (c *Cache) reply(hc http.Context, e *entry, status string) {
+	h := hc.Response().Header()
+	if e.etag != "" {
+		h.Set("ETag", e.etag)
+	}
+	h.Set(HeaderCache, status)
+}
+
+// key returns the cache key of req, false when it must not be cached.
+func (c *Cache) key(req *http2.Request, ctxVal *endpoint.CtxVal) (string, bool) {
+	principal := ""
+	if !c.opts.shared {
+		if p, ok := ctxVal.Get(endpoint.PrincipalKey); ok && p != nil {
+			principal = fmt.Sprint(p)
+		} else if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+			return "", false
+		}
+	}
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.Path)
+	if q := req.URL.Query(); len(q) > 0 {
+		b.WriteByte('?')
+		b.WriteString(sortedQuery(q))
+	}
+	for _, h := range c.opts.vary {
+		b.WriteByte('\n')
+		b.WriteString(h)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+	if principal != "" {
+		b.WriteString("\nprincipal:")
+		b.WriteString(principal)
+	}
+	return b.String(), true
+}
+
+// sortedQuery orders the query by key, values keep their order as it is significant.
+func sortedQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// detached keeps the values of a context but not its cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+type discardWriter struct {
+	header http2.Header
+}
+
+func (w discardWriter) Header() http2.Header        { return w.header }
+func (w discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w discardWriter) WriteHeader(int)             {}
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/transport/http"
+	http2 "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func call(h endpoint.HandlerFunc, method, target string, header http2.Header) (interface{}, *httptest.ResponseRecorder, error) {
+	return callCtx(context.Background(), h, method, target, header, nil)
+}
+
+func callCtx(ctx context.Context, h endpoint.HandlerFunc, method, target string, header http2.Header, principal interface{}) (interface{}, *httptest.ResponseRecorder, error) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, nil).WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	ctxVal := endpoint.NewCtxVal()
+	if principal != nil {
+		ctxVal.Set(endpoint.PrincipalKey, principal)
+	}
+	req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+	hc := http.NewHttpContext(rec, req)
+	resp, err := h(hc, nil)
+	if err == nil {
+		err = hc.JSON(http2.StatusOK, resp)
+	}
+	return resp, rec, err
+}
+
+func TestHitAndNotModified(t *testing.T) {
+	var runs int32
+	h := New().Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return atomic.AddInt32(&runs, 1), nil
+	})
+
+	_, rec, err := call(h, http2.MethodGet, "/users?b=2&a=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get(HeaderCache) != "MISS" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+
+	resp, rec, _ := call(h, http2.MethodGet, "/users?a=1&b=2", nil)
+	if runs != 1 || resp.(int32) != 1 || rec.Header().Get(HeaderCache) != "HIT" {
+		t.Fatalf("runs = %d, X-Cache = %s, want a hit", runs, rec.Header().Get(HeaderCache))
+	}
+
+	_, rec, _ = call(h, http2.MethodGet, "/users?a=1&b=2", http2.Header{"If-None-Match": {etag}})
+	if rec.Code != http2.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("code = %d, body = %q, want 304", rec.Code, rec.Body.String())
+	}
+
+	if _, _, _ = call(h, http2.MethodGet, "/users?a=2", nil); runs != 2 {
+		t.Fatal("another query should miss")
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	var runs int32
+	h := New(WithVaryHeaders("accept-language")).Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return atomic.AddInt32(&runs, 1), nil
+	})
+	_, _, _ = call(h, http2.MethodGet, "/", http2.Header{"Accept-Language": {"en"}})
+	_, _, _ = call(h, http2.MethodGet, "/", http2.Header{"Accept-Language": {"zh"}})
+	_, _, _ = call(h, http2.MethodGet, "/", http2.Header{"Accept-Language": {"en"}})
+	if runs != 2 {
+		t.Fatalf("runs = %d, want 2", runs)
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	var runs int32
+	release := make(chan struct{})
+	h := New().Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return "ok", nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = call(h, http2.MethodGet, "/slow", nil)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if runs != 1 {
+		t.Fatalf("runs = %d, want 1", runs)
+	}
+}
+
+func TestCollapseLeaderCanceled(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := New().Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "ok", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan struct{})
+	go func() {
+		defer close(leader)
+		_, _, _ = callCtx(ctx, h, http2.MethodGet, "/slow", nil, nil)
+	}()
+	<-started
+	follower := make(chan error, 1)
+	go func() {
+		_, _, err := call(h, http2.MethodGet, "/slow", nil)
+		follower <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-follower; err != nil {
+		t.Fatalf("the leader going away should not fail the others: %v", err)
+	}
+	<-leader
+}
+
+func TestPrincipal(t *testing.T) {
+	var runs int32
+	h := New().Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return atomic.AddInt32(&runs, 1), nil
+	})
+	alice, _, _ := callCtx(context.Background(), h, http2.MethodGet, "/me", nil, "alice")
+	bob, _, _ := callCtx(context.Background(), h, http2.MethodGet, "/me", nil, "bob")
+	again, _, _ := callCtx(context.Background(), h, http2.MethodGet, "/me", nil, "alice")
+	if alice == bob || again != alice {
+		t.Fatalf("alice = %v, bob = %v, alice again = %v, responses should be kept per principal", alice, bob, again)
+	}
+
+	auth := http2.Header{"Authorization": {"Bearer t"}}
+	_, _, _ = call(h, http2.MethodGet, "/token", auth)
+	_, rec, _ := call(h, http2.MethodGet, "/token", auth)
+	if runs != 4 || rec.Header().Get(HeaderCache) != "" {
+		t.Fatalf("runs = %d, requests with credentials and no principal should not be cached", runs)
+	}
+
+	shared := New(WithShared(true)).Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return atomic.AddInt32(&runs, 1), nil
+	})
+	_, _, _ = call(shared, http2.MethodGet, "/token", auth)
+	if _, rec, _ := call(shared, http2.MethodGet, "/token", auth); rec.Header().Get(HeaderCache) != "HIT" {
+		t.Fatal("WithShared should cache requests with credentials")
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var runs int32
+	refreshed := make(chan struct{}, 1)
+	h := New(WithTTL(10*time.Millisecond), WithStaleWhileRevalidate(time.Minute)).Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		n := atomic.AddInt32(&runs, 1)
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil
+	})
+
+	_, _, _ = call(h, http2.MethodGet, "/", nil)
+	time.Sleep(20 * time.Millisecond)
+	resp, rec, _ := call(h, http2.MethodGet, "/", nil)
+	if resp.(int32) != 1 || rec.Header().Get(HeaderCache) != "STALE" {
+		t.Fatalf("resp = %v, X-Cache = %s, want the stale response", resp, rec.Header().Get(HeaderCache))
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry was not refreshed")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if resp, _, _ = call(h, http2.MethodGet, "/", nil); resp.(int32) != 2 {
+		t.Fatalf("resp = %v, want the refreshed response", resp)
+	}
+}
+
+func TestInvalidateByTag(t *testing.T) {
+	var runs int32
+	c := New()
+	h := c.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		if hc := ctx.(http.Context); hc.Request().Method == http2.MethodPost {
+			Invalidate(ctx, "users")
+			return nil, nil
+		}
+		Tag(ctx, "users")
+		return atomic.AddInt32(&runs, 1), nil
+	})
+
+	_, _, _ = call(h, http2.MethodGet, "/users", nil)
+	_, _, _ = call(h, http2.MethodGet, "/users", nil)
+	if c.Len() != 1 || runs != 1 {
+		t.Fatalf("len = %d, runs = %d", c.Len(), runs)
+	}
+	_, _, _ = call(h, http2.MethodPost, "/users", nil)
+	if c.Len() != 0 {
+		t.Fatal("write should invalidate tagged entries")
+	}
+	if _, _, _ = call(h, http2.MethodGet, "/users", nil); runs != 2 {
+		t.Fatalf("runs = %d, want 2", runs)
+	}
+}
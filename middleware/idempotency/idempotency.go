@@ -0,0 +1,248 @@
+// Package idempotency replays the result of a request to retries carrying
+// the same Idempotency-Key.
+//
+// A key reused with another request is refused with think.CodeRepeat
+// rather than replaying the result of the first one. On http, where the
+// middleware runs before the request is decoded, the method, uri and raw
+// body tell requests apart.
+//
+// The handler result is saved before the endpoint encoder runs, so a replay
+// goes through the same encoder and yields the identical response. Client
+// faults are replayed as well, server faults release the key for a retry.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
+	"github.com/zander-84/gull/transport/http"
+	"io"
+	"log"
+	http2 "net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderKey      = "Idempotency-Key"
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+const responseKey = "idempotency.response"
+
+// OptionsResponse declares the response type of an endpoint so replays on
+// other instances decode into it, e.g. OptionsResponse(&pbs.Response{}).
+// Without it the type is learned from the first response in this process,
+// a replay with no known type fails with think.CodeException as the
+// encoder of the endpoint could not take it.
+func OptionsResponse(v interface{}) endpoint.Options {
+	return endpoint.OptionsValue(responseKey, reflect.TypeOf(v))
+}
+
+// Option is an idempotency option.
+type Option func(*options)
+
+type options struct {
+	store     Store
+	ttl       time.Duration
+	lockTTL   time.Duration
+	header    string
+	required  bool
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+func WithStore(s Store) Option {
+	return func(o *options) { o.store = s }
+}
+
+// WithTTL sets how long results are replayed, default 24h.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) { o.ttl = d }
+}
+
+// WithLockTTL bounds how long a running request holds its key, default 1 minute.
+func WithLockTTL(d time.Duration) Option {
+	return func(o *options) { o.lockTTL = d }
+}
+
+// WithHeader sets the header or metadata carrying the key, default Idempotency-Key.
+func WithHeader(name string) Option {
+	return func(o *options) { o.header = name }
+}
+
+// WithRequired rejects requests without a key with think.CodeParamError.
+func WithRequired(required bool) Option {
+	return func(o *options) { o.required = required }
+}
+
+// WithCodec sets how responses are stored, default encoding/json.
+func WithCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Option {
+	return func(o *options) {
+		o.marshal = marshal
+		o.unmarshal = unmarshal
+	}
+}
+
+// New returns an idempotency middleware.
+func New(opts ...Option) endpoint.Middleware {
+	o := options{
+		ttl:       24 * time.Hour,
+		lockTTL:   time.Minute,
+		header:    HeaderKey,
+		marshal:   json.Marshal,
+		unmarshal: json.Unmarshal,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.store == nil {
+		o.store = NewMemoryStore()
+	}
+	var types sync.Map // route -> reflect.Type
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return next(ctx, request)
+			}
+			idemKey := tr.RequestHeader().Get(o.header)
+			if idemKey == "" {
+				if o.required {
+					return nil, think.New(think.CodeParamError, "", think.CodeParamError.ToString(), "missing "+o.header)
+				}
+				return next(ctx, request)
+			}
+
+			route, scope := "", ""
+			ctxVal, hasVal := endpoint.GetCtxVal(ctx)
+			if hasVal {
+				route = endpoint.Key(ctxVal.GetMethod(), ctxVal.GetPath())
+				if p, ok := ctxVal.Get(endpoint.PrincipalKey); ok && p != nil {
+					scope = fmt.Sprint(p)
+				}
+			}
+			key := strings.Join([]string{"idempotency", route, scope, idemKey}, "|")
+
+			hash := o.hash(tr, request)
+			rec, err := o.store.Acquire(ctx, key, o.lockTTL)
+			if err == ErrInFlight {
+				return nil, think.New(think.CodeRepeat, "", think.CodeRepeat.ToString(), "request with the same idempotency key is in progress")
+			}
+			if err != nil {
+				return nil, think.New(think.CodeUnavailable, "", think.CodeUnavailable.ToString(), err.Error())
+			}
+			if rec != nil {
+				if rec.Hash != "" && hash != "" && rec.Hash != hash {
+					return nil, think.New(think.CodeRepeat, "", think.CodeRepeat.ToString(), o.header+" was used with another request")
+				}
+				tr.ReplyHeader().Set(HeaderReplayed, "true")
+				return o.replay(rec, responseType(ctxVal, hasVal, &types, route))
+			}
+
+			defer func() {
+				// a panic, recovered further up, must not hold the key for lockTTL
+				if r := recover(); r != nil {
+					_ = o.store.Release(ctx, key)
+					panic(r)
+				}
+			}()
+			resp, err := next(ctx, request)
+			if err != nil {
+				if e := think.FromError(err); e.Code.HttpCode() < 500 {
+					r := e.Response
+					o.save(ctx, key, &Record{Error: &r, Hash: hash})
+				} else {
+					_ = o.store.Release(ctx, key)
+				}
+				return resp, err
+			}
+
+			data, mErr := o.marshal(resp)
+			if mErr != nil {
+				log.Printf("[Idempotency] marshal response err: %v", mErr)
+				_ = o.store.Release(ctx, key)
+				return resp, nil
+			}
+			if resp != nil {
+				types.Store(route, reflect.TypeOf(resp))
+			}
+			o.save(ctx, key, &Record{Response: data, Hash: hash})
+			return resp, nil
+		}
+	}
+}
+
+// hash digests the method, uri and raw body of a http request, the decoded
+// request otherwise. It is "" when the request cannot be read.
+func (o *options) hash(tr transport.Transporter, request interface{}) string {
+	h := sha256.New()
+	if c, ok := tr.(http.Context); ok {
+		req := c.Request()
+		h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+		if req.Body != nil && req.Body != http2.NoBody {
+			body, err := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+			if err != nil {
+				return ""
+			}
+			h.Write(body)
+		}
+	} else {
+		data, err := o.marshal(request)
+		if err != nil {
+			return ""
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (o *options) save(ctx context.Context, key string, rec *Record) {
+	if err := o.store.Save(ctx, key, rec, o.ttl); err != nil {
+		log.Printf("[Idempotency] save %s err: %v", key, err)
+		_ = o.store.Release(ctx, key)
+	}
+}
+
+func responseType(ctxVal *endpoint.CtxVal, ok bool, types *sync.Map, route string) reflect.Type {
+	if ok {
+		if t, ok := ctxVal.Value(responseKey); ok {
+			return t.(reflect.Type)
+		}
+	}
+	if t, ok := types.Load(route); ok {
+		return t.(reflect.Type)
+	}
+	return nil
+}
+
+func (o *options) replay(rec *Record, t reflect.Type) (interface{}, error) {
+	if rec.Error != nil {
+		return nil, &think.Error{Response: *rec.Error}
+	}
+	if t == nil {
+		return nil, think.New(think.CodeException, "", think.CodeException.ToString(), "no response type to replay, see idempotency.OptionsResponse")
+	}
+	if t.Kind() == reflect.Ptr {
+		v := reflect.New(t.Elem())
+		if err := o.unmarshal(rec.Response, v.Interface()); err != nil {
+			return nil, think.New(think.CodeException, "", think.CodeException.ToString(), err.Error())
+		}
+		return v.Interface(), nil
+	}
+	v := reflect.New(t)
+	if err := o.unmarshal(rec.Response, v.Interface()); err != nil {
+		return nil, think.New(think.CodeException, "", think.CodeException.ToString(), err.Error())
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,191 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	http2 "net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type order struct {
+	ID    int
+	Items []string
+}
+
+func call(h endpoint.HandlerFunc, key string) (interface{}, *httptest.ResponseRecorder, error) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http2.MethodPost, "/orders", nil)
+	req.Header.Set(HeaderKey, key)
+	ctxVal := endpoint.NewCtxVal()
+	ctxVal.SetRoute(endpoint.MethodPost, "/orders")
+	req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+	resp, err := h(http.NewHttpContext(rec, req), nil)
+	return resp, rec, err
+}
+
+func TestReplay(t *testing.T) {
+	runs := 0
+	h := New()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		runs++
+		return &order{ID: runs, Items: []string{"a"}}, nil
+	})
+
+	first, _, err := call(h, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, rec, err := call(h, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Fatalf("handler ran %d times, want 1", runs)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("replay = %#v, want %#v", second, first)
+	}
+	if rec.Header().Get(HeaderReplayed) != "true" {
+		t.Fatal("replayed response should be marked")
+	}
+	if _, _, _ = call(h, "k2"); runs != 2 {
+		t.Fatal("a new key should run the handler")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := New()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return "ok", nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = call(h, "k")
+	}()
+	<-started
+	if _, _, err := call(h, "k"); think.GetCode(err) != think.CodeRepeat {
+		t.Fatalf("code = %v, want CodeRepeat", think.GetCode(err))
+	}
+	close(release)
+	<-done
+}
+
+func TestErrors(t *testing.T) {
+	var err error
+	runs := 0
+	h := New()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		runs++
+		return nil, err
+	})
+
+	err = errors.New("db down")
+	_, _, _ = call(h, "server")
+	_, _, _ = call(h, "server")
+	if runs != 2 {
+		t.Fatalf("server faults should be retried, runs = %d", runs)
+	}
+
+	err = think.New(think.CodeParamError, "B1", "bad", "")
+	_, _, _ = call(h, "client")
+	_, _, replayed := call(h, "client")
+	if runs != 3 {
+		t.Fatalf("client faults should be replayed, runs = %d", runs)
+	}
+	if e := think.FromError(replayed); e.Code != think.CodeParamError || e.BizCode != "B1" {
+		t.Fatalf("unexpected replayed error %v", replayed)
+	}
+}
+
+// rmc serves POST /orders through an endpoint with a typed encoder, the
+// middleware then runs before the body is decoded.
+func rmc(runs *int, opts ...endpoint.Options) endpoint.HandlerFunc {
+	r := endpoint.NewRmc()
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodPost, "/orders",
+		func(ctx context.Context, request interface{}) (interface{}, error) {
+			*runs++
+			return &order{ID: *runs, Items: request.(*order).Items}, nil
+		},
+		func(ctx context.Context, p endpoint.Protocol, in interface{}) (interface{}, error) {
+			var o order
+			return &o, json.NewDecoder(ctx.(http.Context).Request().Body).Decode(&o)
+		},
+		func(ctx context.Context, p endpoint.Protocol, in interface{}) (interface{}, error) {
+			return in, ctx.(http.Context).JSON(http2.StatusOK, in.(*order))
+		}, opts...)
+	return r.MustGetEndpoint(endpoint.MethodPost, "/orders")
+}
+
+func post(h endpoint.HandlerFunc, key, body string) (*httptest.ResponseRecorder, error) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http2.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set(HeaderKey, key)
+	ctxVal := endpoint.NewCtxVal()
+	ctxVal.SetProtocol(endpoint.Http)
+	req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+	_, err := h(http.NewHttpContext(rec, req), nil)
+	return rec, err
+}
+
+func TestKeyReuse(t *testing.T) {
+	runs := 0
+	store := NewMemoryStore()
+	h := rmc(&runs, endpoint.OptionsMiddleware(New(WithStore(store))), OptionsResponse(&order{}))
+
+	first, err := post(h, "k", `{"Items":["a"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := post(h, "k", `{"Items":["a"]}`)
+	if err != nil || runs != 1 {
+		t.Fatalf("a retry should be replayed, err = %v, runs = %d", err, runs)
+	}
+	if second.Body.String() != first.Body.String() || second.Header().Get(HeaderReplayed) != "true" {
+		t.Fatalf("replay %q, want %q", second.Body.String(), first.Body.String())
+	}
+	if _, err := post(h, "k", `{"Items":["b"]}`); !think.IsErrRepeat(err) {
+		t.Fatalf("err = %v, want CodeRepeat", err)
+	}
+	if runs != 1 {
+		t.Fatalf("a reused key should not run the handler, runs = %d", runs)
+	}
+
+	// another instance without OptionsResponse can not hand raw json to the encoder
+	other := rmc(&runs, endpoint.OptionsMiddleware(New(WithStore(store))))
+	if _, err := post(other, "k", `{"Items":["a"]}`); think.GetCode(err) != think.CodeException || runs != 1 {
+		t.Fatalf("err = %v, runs = %d, want CodeException", err, runs)
+	}
+}
+
+func TestPanicReleasesKey(t *testing.T) {
+	runs := 0
+	h := New(WithLockTTL(time.Hour))(func(ctx context.Context, request interface{}) (interface{}, error) {
+		runs++
+		if runs == 1 {
+			panic("boom")
+		}
+		return "ok", nil
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic should go on up the stack")
+			}
+		}()
+		_, _, _ = call(h, "k")
+	}()
+	if resp, _, err := call(h, "k"); err != nil || resp != "ok" {
+		t.Fatalf("the key should be released after a panic, resp = %v, err = %v", resp, err)
+	}
+}
@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"github.com/zander-84/gull/think"
+	"sync"
+	"time"
+)
+
+// ErrInFlight is returned by Store.Acquire while the first request of a key runs.
+var ErrInFlight = errors.New("idempotency: request in flight")
+
+// Record is the saved outcome of a request.
+type Record struct {
+	Response []byte          `json:"response,omitempty"`
+	Error    *think.Response `json:"error,omitempty"`
+	// Hash is the sha256 of the request, to tell a retry from a reused key.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Store keeps idempotency records. Implementations over a shared storage must
+// make Acquire atomic, like SET NX.
+type Store interface {
+	// Acquire locks key for lockTTL and returns nil, nil for the first request.
+	// A finished key returns its record, a locked one ErrInFlight.
+	Acquire(ctx context.Context, key string, lockTTL time.Duration) (*Record, error)
+	// Save stores the record of a locked key for ttl.
+	Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Release drops the lock so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type entry struct {
+	rec      *Record
+	expireAt time.Time
+}
+
+type MemoryStore struct {
+	lock      sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry)}
+}
+
+func (m *MemoryStore) Acquire(_ context.Context, key string, lockTTL time.Duration) (*Record, error) {
+	now := time.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, e := range m.entries {
+			if now.After(e.expireAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	if e, ok := m.entries[key]; ok && now.Before(e.expireAt) {
+		if e.rec == nil {
+			return nil, ErrInFlight
+		}
+		return e.rec, nil
+	}
+	m.entries[key] = &entry{expireAt: now.Add(lockTTL)}
+	return nil, nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, key string, rec *Record, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries[key] = &entry{rec: rec, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if e, ok := m.entries[key]; ok && e.rec == nil {
+		delete(m.entries, key)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+type bucket struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+
+	// sliding window
+	start time.Time
+	prev  int64
+	curr  int64
+
+	expireAt time.Time
+}
+
+// MemoryStore keeps limiter state in process memory.
+type MemoryStore struct {
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	sweep     time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		sweep:   time.Minute,
+	}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, rule Rule, now time.Time) (Result, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if now.Sub(m.lastSweep) > m.sweep {
+		for k, b := range m.buckets {
+			if now.After(b.expireAt) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.burst()), last: now, start: now.Truncate(rule.Window)}
+		m.buckets[key] = b
+	}
+	b.expireAt = now.Add(2 * rule.Window)
+
+	if rule.Algorithm == SlidingWindow {
+		return b.slidingWindow(rule, now), nil
+	}
+	return b.tokenBucket(rule, now), nil
+}
+
+func (b *bucket) tokenBucket(rule Rule, now time.Time) Result {
+	burst := float64(rule.burst())
+	rate := float64(rule.Limit) / float64(rule.Window) // tokens per nanosecond
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)*rate)
+		b.last = now
+	}
+
+	res := Result{Limit: rule.burst()}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	res.Remaining = int64(b.tokens)
+	res.Reset = time.Duration(math.Ceil((burst - b.tokens) / rate))
+	return res
+}
+
+func (b *bucket) slidingWindow(rule Rule, now time.Time) Result {
+	ws := now.Truncate(rule.Window)
+	if !ws.Equal(b.start) {
+		if ws.Sub(b.start) == rule.Window {
+			b.prev = b.curr
+		} else {
+			b.prev = 0
+		}
+		b.curr = 0
+		b.start = ws
+	}
+
+	elapsed := now.Sub(ws)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	estimate := float64(b.prev)*weight + float64(b.curr)
+
+	res := Result{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimate+1 <= float64(rule.Limit) {
+		b.curr++
+		res.Allowed = true
+		res.Remaining = int64(float64(rule.Limit) - estimate - 1)
+		return res
+	}
+
+	if b.curr+1 > rule.Limit || b.prev == 0 {
+		res.RetryAfter = rule.Window - elapsed
+	} else {
+		// the previous window must fade until prev*weight+curr+1 <= limit
+		need := 1 - float64(rule.Limit-b.curr-1)/float64(b.prev)
+		res.RetryAfter = time.Duration(need*float64(rule.Window)) - elapsed
+	}
+	if res.RetryAfter <= 0 {
+		res.RetryAfter = time.Millisecond
+	}
+	return res
+}
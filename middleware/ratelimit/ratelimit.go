@@ -0,0 +1,169 @@
+// Package ratelimit rejects requests over a quota with think.CodeTooManyRequests.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc returns the part of the limiter key derived from a request.
+type KeyFunc func(ctx context.Context) string
+
+// Option is a rate limit option.
+type Option func(*options)
+
+type options struct {
+	rule     Rule
+	store    Store
+	keys     []KeyFunc
+	prefix   string
+	failOpen bool
+	now      func() time.Time
+}
+
+// WithRule sets the quota, default 100 requests per second with a token bucket.
+func WithRule(rule Rule) Option {
+	return func(o *options) { o.rule = rule }
+}
+
+// WithStore sets the state store, default NewMemoryStore().
+func WithStore(store Store) Option {
+	return func(o *options) { o.store = store }
+}
+
+// WithKey sets how requests are grouped, default ByRoute and ByIP.
+func WithKey(keys ...KeyFunc) Option {
+	return func(o *options) { o.keys = keys }
+}
+
+// WithPrefix namespaces the keys in a shared store.
+func WithPrefix(prefix string) Option {
+	return func(o *options) { o.prefix = prefix }
+}
+
+// WithFailOpen lets requests pass when the store fails, default true.
+func WithFailOpen(failOpen bool) Option {
+	return func(o *options) { o.failOpen = failOpen }
+}
+
+// New returns a middleware limiting requests per key.
+func New(opts ...Option) endpoint.Middleware {
+	o := options{
+		rule:     Rule{Algorithm: TokenBucket, Limit: 100, Window: time.Second},
+		keys:     []KeyFunc{ByRoute(), ByIP()},
+		prefix:   "ratelimit",
+		failOpen: true,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.store == nil {
+		o.store = NewMemoryStore()
+	}
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			res, err := o.store.Take(ctx, o.key(ctx), o.rule, o.now())
+			if err != nil {
+				if o.failOpen {
+					log.Printf("[RateLimit] store err: %v", err)
+					return next(ctx, request)
+				}
+				return nil, think.New(think.CodeUnavailable, "", think.CodeUnavailable.ToString(), err.Error())
+			}
+
+			setHeaders(ctx, res)
+			if !res.Allowed {
+				return nil, think.New(think.CodeTooManyRequests, "", think.CodeTooManyRequests.ToString(), "rate limit exceeded")
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+func (o *options) key(ctx context.Context) string {
+	parts := make([]string, 0, len(o.keys)+1)
+	parts = append(parts, o.prefix)
+	for _, k := range o.keys {
+		part := k(ctx)
+		if part == "" {
+			part = "-"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "|")
+}
+
+func setHeaders(ctx context.Context, res Result) {
+	tr, ok := transport.FromServerContext(ctx)
+	if !ok || tr.Kind() != transport.KindHTTP {
+		return
+	}
+	h := tr.ReplyHeader()
+	h.Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(seconds(res.Reset), 10))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// ByRoute groups requests by the registered method and path.
+func ByRoute() KeyFunc {
+	return func(ctx context.Context) string {
+		if v, ok := endpoint.GetCtxVal(ctx); ok {
+			return endpoint.Key(v.GetMethod(), v.GetPath())
+		}
+		return ""
+	}
+}
+
+// ByIP groups requests by client ip, behind a proxy see
+// http.ServerTrustedProxies.
+func ByIP() KeyFunc {
+	return func(ctx context.Context) string {
+		tr, _ := transport.FromServerContext(ctx)
+		if c, ok := tr.(interface{ RemoteIP() string }); ok {
+			return c.RemoteIP()
+		}
+		return ""
+	}
+}
+
+// ByHeader groups requests by a http header or grpc metadata value.
+func ByHeader(name string) KeyFunc {
+	return func(ctx context.Context) string {
+		if tr, ok := transport.FromServerContext(ctx); ok {
+			return tr.RequestHeader().Get(name)
+		}
+		return ""
+	}
+}
+
+// ByPrincipal groups requests by the authenticated caller stored under endpoint.PrincipalKey.
+func ByPrincipal() KeyFunc {
+	return func(ctx context.Context) string {
+		v, ok := endpoint.GetCtxVal(ctx)
+		if !ok {
+			return ""
+		}
+		p, ok := v.Get(endpoint.PrincipalKey)
+		if !ok || p == nil {
+			return ""
+		}
+		return fmt.Sprint(p)
+	}
+}
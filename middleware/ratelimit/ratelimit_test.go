@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	http2 "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	s := NewMemoryStore()
+	rule := Rule{Algorithm: TokenBucket, Limit: 2, Window: time.Second}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 2; i++ {
+		if res, _ := s.Take(context.Background(), "k", rule, now); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	res, _ := s.Take(context.Background(), "k", rule, now)
+	if res.Allowed {
+		t.Fatal("bucket should be empty")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("RetryAfter = %v, want 500ms", res.RetryAfter)
+	}
+	if res, _ = s.Take(context.Background(), "k", rule, now.Add(500*time.Millisecond)); !res.Allowed {
+		t.Fatal("a token should be refilled")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	s := NewMemoryStore()
+	rule := Rule{Algorithm: SlidingWindow, Limit: 4, Window: time.Second}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 4; i++ {
+		if res, _ := s.Take(context.Background(), "k", rule, now); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if res, _ := s.Take(context.Background(), "k", rule, now.Add(100*time.Millisecond)); res.Allowed {
+		t.Fatal("window should be full")
+	}
+	// half of the previous window still counts: 4*0.5 = 2
+	now = now.Add(1500 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if res, _ := s.Take(context.Background(), "k", rule, now); !res.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	res, _ := s.Take(context.Background(), "k", rule, now)
+	if res.Allowed {
+		t.Fatal("weighted window should be full")
+	}
+	if res.RetryAfter != 250*time.Millisecond {
+		t.Fatalf("RetryAfter = %v, want 250ms", res.RetryAfter)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New(WithRule(Rule{Limit: 1, Window: time.Minute}), WithKey(ByRoute(), ByHeader("X-Api-Key")))
+	h := m(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	call := func(key string) (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http2.MethodGet, "/a", nil)
+		req.Header.Set("X-Api-Key", key)
+		ctxVal := endpoint.NewCtxVal()
+		ctxVal.SetProtocol(endpoint.Http)
+		ctxVal.SetRoute(endpoint.MethodGet, "/a")
+		req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+		_, err := h(http.NewHttpContext(rec, req), nil)
+		return rec, err
+	}
+
+	if _, err := call("a"); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := call("a")
+	if think.GetCode(err) != think.CodeTooManyRequests {
+		t.Fatalf("code = %v, want CodeTooManyRequests", think.GetCode(err))
+	}
+	if rec.Header().Get("Retry-After") != "60" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("unexpected headers %v", rec.Header())
+	}
+	if _, err := call("b"); err != nil {
+		t.Fatal("another key should have its own quota")
+	}
+}
+
+func TestByIPWrapped(t *testing.T) {
+	h := New(WithRule(Rule{Limit: 1, Window: time.Minute}), WithKey(ByIP()))(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	call := func(peer string) error {
+		req := httptest.NewRequest(http2.MethodGet, "/a", nil)
+		req.RemoteAddr = peer
+		ctx, cancel := context.WithTimeout(http.NewHttpContext(httptest.NewRecorder(), req), time.Second)
+		defer cancel()
+		_, err := h(ctx, nil)
+		return err
+	}
+
+	if err := call("1.1.1.1:4000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("2.2.2.2:4000"); err != nil {
+		t.Fatal("a wrapped context should still be keyed by its own ip")
+	}
+	if err := call("1.1.1.1:4001"); think.GetCode(err) != think.CodeTooManyRequests {
+		t.Fatalf("code = %v, want CodeTooManyRequests", think.GetCode(err))
+	}
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Algorithm int
+
+const (
+	// TokenBucket refills Limit tokens every Window and allows bursts up to Burst.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit requests in any Window long period.
+	SlidingWindow
+)
+
+// Rule describes how many requests a key may issue.
+type Rule struct {
+	Algorithm Algorithm
+	Limit     int64
+	Window    time.Duration
+	// Burst is the bucket capacity of TokenBucket, defaults to Limit.
+	Burst int64
+}
+
+func (r Rule) burst() int64 {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// Result is the decision for one request.
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration // when to retry if not allowed
+	Reset      time.Duration // when the quota is fully restored
+}
+
+// Store keeps the limiter state. Implementations backed by a shared storage
+// coordinate the limit across instances, so Take must be atomic per key.
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule, now time.Time) (Result, error)
+}
@@ -0,0 +1,142 @@
+// Package requestid gives every request an id, accepted from the caller or
+// generated, and carries it to the reply, outbound calls and log records.
+//
+// Install the middleware ahead of accesslog so generated ids are logged too.
+package requestid
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"log"
+	http2 "net/http"
+	"strings"
+)
+
+// Header is the http header carrying the id, grpc uses its lower case form as metadata key.
+const Header = "X-Request-ID"
+
+// maxLen bounds the ids accepted from callers.
+const maxLen = 128
+
+type requestIDKey struct{}
+
+// Option is a request id option.
+type Option func(*options)
+
+type options struct {
+	generate func() string
+}
+
+// WithGenerator sets how missing ids are made, default a random uuid.
+func WithGenerator(generate func() string) Option {
+	return func(o *options) { o.generate = generate }
+}
+
+// New returns a middleware that stores the request id in endpoint.CtxVal and
+// echoes it in the reply header. Ids which are too long or not printable are replaced.
+func New(opts ...Option) endpoint.Middleware {
+	o := options{
+		generate: func() string { return uuid.New().String() },
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			tr, hasTr := transport.FromServerContext(ctx)
+			var id string
+			if hasTr {
+				id = tr.RequestHeader().Get(Header)
+			}
+			if !valid(id) {
+				id = o.generate()
+			}
+			if v, ok := endpoint.GetCtxVal(ctx); ok {
+				v.Set(endpoint.RequestIDKey, id)
+			}
+			if hasTr {
+				tr.ReplyHeader().Set(Header, id)
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id, for outbound calls started outside an endpoint.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request id of ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if v, ok := endpoint.GetCtxVal(ctx); ok {
+		if id, ok := v.Get(endpoint.RequestIDKey); ok {
+			s, _ := id.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// Printf logs through the standard logger with the request id of ctx as prefix.
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	if id := FromContext(ctx); id != "" {
+		log.Printf("[%s] %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+type roundTripper struct {
+	next http2.RoundTripper
+}
+
+// RoundTripper sets the request id of the request context on outbound http
+// requests, next defaults to http.DefaultTransport.
+func RoundTripper(next http2.RoundTripper) http2.RoundTripper {
+	if next == nil {
+		next = http2.DefaultTransport
+	}
+	return roundTripper{next: next}
+}
+
+func (rt roundTripper) RoundTrip(req *http2.Request) (*http2.Response, error) {
+	id := FromContext(req.Context())
+	if id == "" || req.Header.Get(Header) != "" {
+		return rt.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, id)
+	return rt.next.RoundTrip(req)
+}
+
+// UnaryClientInterceptor sets the request id of ctx on outbound grpc calls.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(Header), id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
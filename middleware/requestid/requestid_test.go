@@ -0,0 +1,74 @@
+package requestid
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	http2 "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func call(id string) (string, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http2.MethodGet, "/", nil)
+	if id != "" {
+		req.Header.Set(Header, id)
+	}
+	req = req.WithContext(endpoint.WithContext(req.Context(), endpoint.NewCtxVal()))
+	var got string
+	h := New(WithGenerator(func() string { return "generated" }))(func(ctx context.Context, request interface{}) (interface{}, error) {
+		got = FromContext(ctx)
+		return nil, nil
+	})
+	_, _ = h(http.NewHttpContext(rec, req), nil)
+	return got, rec
+}
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"abc-123", "abc-123"},
+		{"", "generated"},
+		{"has space", "generated"},
+		{strings.Repeat("a", maxLen+1), "generated"},
+	}
+	for _, c := range cases {
+		got, rec := call(c.in)
+		if got != c.want || rec.Header().Get(Header) != c.want {
+			t.Fatalf("id %q: ctx = %q, reply = %q, want %q", c.in, got, rec.Header().Get(Header), c.want)
+		}
+	}
+}
+
+type roundTripFunc func(*http2.Request) (*http2.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http2.Request) (*http2.Response, error) { return f(req) }
+
+func TestOutbound(t *testing.T) {
+	ctx := NewContext(context.Background(), "rid")
+
+	var sent string
+	rt := RoundTripper(roundTripFunc(func(req *http2.Request) (*http2.Response, error) {
+		sent = req.Header.Get(Header)
+		return &http2.Response{StatusCode: http2.StatusOK}, nil
+	}))
+	req, _ := http2.NewRequestWithContext(ctx, http2.MethodGet, "http://example.com", nil)
+	if _, err := rt.RoundTrip(req); err != nil || sent != "rid" {
+		t.Fatalf("sent = %q, err = %v", sent, err)
+	}
+	if req.Header.Get(Header) != "" {
+		t.Fatal("the caller's request should not be modified")
+	}
+
+	err := UnaryClientInterceptor()(ctx, "/a.B/C", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = strings.Join(md.Get(Header), ",")
+		return nil
+	})
+	if err != nil || sent != "rid" {
+		t.Fatalf("sent = %q, err = %v", sent, err)
+	}
+}
@@ -0,0 +1,41 @@
+package shedding
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	cpuOnce  sync.Once
+	cpuUsage int64 // permille of all cores, smoothed
+)
+
+// CPUUsage returns the smoothed process cpu usage in permille of all cores.
+// It is always 0 on platforms without getrusage.
+func CPUUsage() int64 {
+	cpuOnce.Do(func() {
+		go sampleCPU(500 * time.Millisecond)
+	})
+	return atomic.LoadInt64(&cpuUsage)
+}
+
+func sampleCPU(interval time.Duration) {
+	prevCPU, ok := cpuTime()
+	if !ok {
+		return
+	}
+	prevWall := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cpu, _ := cpuTime()
+		wall := time.Now()
+		usage := float64(cpu-prevCPU) / float64(wall.Sub(prevWall)) / float64(runtime.NumCPU()) * 1000
+		prevCPU, prevWall = cpu, wall
+
+		old := atomic.LoadInt64(&cpuUsage)
+		atomic.StoreInt64(&cpuUsage, int64(float64(old)*0.8+usage*0.2))
+	}
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package shedding
+
+import (
+	"syscall"
+	"time"
+)
+
+func cpuTime() (time.Duration, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), true
+}
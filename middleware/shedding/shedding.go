@@ -0,0 +1,205 @@
+// Package shedding rejects excess load early with think.CodeUnavailable.
+//
+// The limiter follows BBR: the number of requests a service can hold is
+// the largest throughput seen in the window times the smallest latency.
+// Once cpu usage passes the threshold, requests in flight above that
+// product are dropped, and dropping goes on for a cool down period after
+// the last drop so the service does not flap.
+package shedding
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+type Priority int
+
+const (
+	// PriorityNormal is used by endpoints without a priority.
+	PriorityNormal Priority = iota
+	// PriorityLow is shed before the limit is reached.
+	PriorityLow
+	// PriorityCritical is never shed, use it for health and admin routes.
+	PriorityCritical
+)
+
+const priorityKey = "shedding.priority"
+
+// OptionsPriority sets the priority class of an endpoint or group.
+func OptionsPriority(p Priority) endpoint.Options {
+	return endpoint.OptionsValue(priorityKey, p)
+}
+
+// ErrShed is returned for dropped requests.
+var ErrShed = think.New(think.CodeUnavailable, "", think.CodeUnavailable.ToString(), "server overloaded")
+
+// Option is a shedding option.
+type Option func(*options)
+
+type options struct {
+	window       time.Duration
+	buckets      int
+	cpuThreshold int64
+	cpu          func() int64
+	coolDown     time.Duration
+	lowRatio     float64
+}
+
+// WithWindow sets the statistic window and its bucket count, default 10s and 100 buckets.
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(o *options) {
+		o.window = window
+		o.buckets = buckets
+	}
+}
+
+// WithCPUThreshold sets the cpu usage in permille that starts shedding, default 800.
+func WithCPUThreshold(permille int64) Option {
+	return func(o *options) { o.cpuThreshold = permille }
+}
+
+// WithCPU replaces the cpu usage source, default CPUUsage.
+func WithCPU(cpu func() int64) Option {
+	return func(o *options) { o.cpu = cpu }
+}
+
+// WithCoolDown sets how long shedding goes on after the last drop, default 1s.
+func WithCoolDown(d time.Duration) Option {
+	return func(o *options) { o.coolDown = d }
+}
+
+// WithLowRatio sets the share of the limit low priority requests may use, default 0.8.
+func WithLowRatio(ratio float64) Option {
+	return func(o *options) { o.lowRatio = ratio }
+}
+
+// Stat is a snapshot of the limiter.
+type Stat struct {
+	CPU         int64
+	InFlight    int64
+	MaxInFlight int64
+	MaxPass     int64
+	MinRT       float64
+}
+
+// Limiter decides whether a request is shed.
+type Limiter struct {
+	opts     options
+	stat     *window
+	inFlight int64
+	prevDrop int64 // unix nano of the last drop
+	perSec   float64
+}
+
+func NewLimiter(opts ...Option) *Limiter {
+	o := options{
+		window:       10 * time.Second,
+		buckets:      100,
+		cpuThreshold: 800,
+		cpu:          CPUUsage,
+		coolDown:     time.Second,
+		lowRatio:     0.8,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w := newWindow(o.window, o.buckets)
+	return &Limiter{
+		opts:   o,
+		stat:   w,
+		perSec: float64(time.Second) / float64(w.size),
+	}
+}
+
+func (l *Limiter) maxInFlight(now time.Time) int64 {
+	maxPass, minRT := l.stat.stat(now)
+	return int64(math.Ceil(float64(maxPass) * minRT * l.perSec / 1000))
+}
+
+func (l *Limiter) shouldDrop(now time.Time, p Priority) bool {
+	if p == PriorityCritical {
+		return false
+	}
+	if l.opts.cpu() < l.opts.cpuThreshold {
+		prev := atomic.LoadInt64(&l.prevDrop)
+		if prev == 0 {
+			return false
+		}
+		if now.UnixNano()-prev > int64(l.opts.coolDown) {
+			atomic.StoreInt64(&l.prevDrop, 0)
+			return false
+		}
+	}
+
+	limit := float64(l.maxInFlight(now))
+	if p == PriorityLow {
+		limit *= l.opts.lowRatio
+	}
+	inFlight := atomic.LoadInt64(&l.inFlight)
+	if inFlight > 1 && float64(inFlight) > limit {
+		atomic.StoreInt64(&l.prevDrop, now.UnixNano())
+		return true
+	}
+	return false
+}
+
+// Allow admits a request of priority p. The returned done must be called
+// when the request finishes.
+func (l *Limiter) Allow(p Priority) (func(), error) {
+	now := time.Now()
+	if l.shouldDrop(now, p) {
+		return nil, ErrShed
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	return func() {
+		atomic.AddInt64(&l.inFlight, -1)
+		l.stat.add(time.Now(), time.Since(now))
+	}, nil
+}
+
+func (l *Limiter) Stat() Stat {
+	now := time.Now()
+	maxPass, minRT := l.stat.stat(now)
+	return Stat{
+		CPU:         l.opts.cpu(),
+		InFlight:    atomic.LoadInt64(&l.inFlight),
+		MaxInFlight: l.maxInFlight(now),
+		MaxPass:     maxPass,
+		MinRT:       minRT,
+	}
+}
+
+// New returns a middleware shedding load for the group it is attached to.
+func New(opts ...Option) endpoint.Middleware {
+	return Middleware(NewLimiter(opts...))
+}
+
+// Middleware sheds load with an existing limiter.
+func Middleware(l *Limiter) endpoint.Middleware {
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			done, err := l.Allow(priority(ctx))
+			if err != nil {
+				return nil, err
+			}
+			defer done()
+			return next(ctx, request)
+		}
+	}
+}
+
+func priority(ctx context.Context) Priority {
+	v, ok := endpoint.GetCtxVal(ctx)
+	if !ok {
+		return PriorityNormal
+	}
+	p, _ := v.Value(priorityKey)
+	if p, ok := p.(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
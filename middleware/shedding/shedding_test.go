@@ -0,0 +1,61 @@
+package shedding
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShedding(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-release
+		return "ok", nil
+	}
+
+	r := endpoint.NewRmc().Group("/api", endpoint.OptionsMiddleware(New(WithCPU(func() int64 { return 1000 }))))
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodGet, "/work", handler, nil, nil)
+	r.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodGet, "/health", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "ok", nil
+	}, nil, nil, OptionsPriority(PriorityCritical))
+
+	call := func(path string) error {
+		_, err := r.MustGetEndpoint(endpoint.MethodGet, path)(endpoint.WithContext(context.Background(), nil), nil)
+		return err
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = call("/work")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := call("/work"); think.GetCode(err) != think.CodeUnavailable {
+		t.Fatalf("overloaded group should shed, got %v", err)
+	}
+	if err := call("/health"); err != nil {
+		t.Fatalf("critical endpoint must not be shed, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestNoSheddingWhenIdle(t *testing.T) {
+	l := NewLimiter(WithCPU(func() int64 { return 0 }))
+	for i := 0; i < 100; i++ {
+		if _, err := l.Allow(PriorityNormal); err != nil {
+			t.Fatal("cold cpu should not shed")
+		}
+	}
+	if l.Stat().InFlight != 100 {
+		t.Fatalf("InFlight = %d, want 100", l.Stat().InFlight)
+	}
+}
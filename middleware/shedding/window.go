@@ -0,0 +1,72 @@
+package shedding
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	start time.Time
+	pass  int64
+	rtSum int64 // milliseconds
+	rtCnt int64
+}
+
+// window is a rolling window of completed requests and their latency.
+type window struct {
+	lock    sync.Mutex
+	size    time.Duration
+	buckets []bucket
+}
+
+func newWindow(length time.Duration, buckets int) *window {
+	if buckets < 1 {
+		buckets = 1
+	}
+	return &window{
+		size:    length / time.Duration(buckets),
+		buckets: make([]bucket, buckets),
+	}
+}
+
+func (w *window) add(now time.Time, rt time.Duration) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	start := now.Truncate(w.size)
+	bk := &w.buckets[int(start.UnixNano()/int64(w.size))%len(w.buckets)]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	bk.pass++
+	bk.rtSum += rt.Milliseconds()
+	bk.rtCnt++
+}
+
+// stat returns the largest pass count and the smallest average latency in
+// milliseconds of the finished buckets, both at least 1.
+func (w *window) stat(now time.Time) (maxPass int64, minRT float64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	current := now.Truncate(w.size)
+	length := w.size * time.Duration(len(w.buckets))
+	minRT = math.MaxFloat64
+	for _, bk := range w.buckets {
+		if bk.start.Equal(current) || now.Sub(bk.start) >= length || bk.rtCnt == 0 {
+			continue
+		}
+		if bk.pass > maxPass {
+			maxPass = bk.pass
+		}
+		if rt := float64(bk.rtSum) / float64(bk.rtCnt); rt < minRT {
+			minRT = rt
+		}
+	}
+	if maxPass < 1 {
+		maxPass = 1
+	}
+	if minRT == math.MaxFloat64 || minRT < 1 {
+		minRT = 1
+	}
+	return maxPass, minRT
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Exporter sends ended, sampled spans to a backend.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+var _ Exporter = (*Recorder)(nil)
+
+// Recorder keeps spans in memory, for tests.
+type Recorder struct {
+	lock  sync.Mutex
+	spans []*Span
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) ExportSpans(_ context.Context, spans []*Span) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *Recorder) Shutdown(context.Context) error { return nil }
+
+// Spans returns the recorded spans in the order they ended.
+func (r *Recorder) Spans() []*Span {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
+
+// Reset drops the recorded spans.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = nil
+}
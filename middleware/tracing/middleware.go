@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	http2 "net/http"
+	"strconv"
+)
+
+// Middleware starts a server span per endpoint call, continuing the trace of
+// the caller's traceparent. Use it with endpoint.OptionsInterceptor so the
+// span covers the encoders too.
+func (t *Tracer) Middleware() endpoint.Middleware {
+	return func(next endpoint.HandlerFunc) endpoint.HandlerFunc {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctxVal, ok := endpoint.GetCtxVal(ctx)
+			if !ok {
+				return next(ctx, request)
+			}
+
+			var parent SpanContext
+			tr, hasTr := transport.FromServerContext(ctx)
+			if hasTr {
+				if sc, ok := Extract(tr.RequestHeader()); ok {
+					parent = sc
+				}
+			}
+			span := t.newSpan(parent, string(ctxVal.GetMethod())+" "+ctxVal.GetPath(), SpanKindServer)
+			span.SetAttribute("endpoint.protocol", string(ctxVal.GetProtocol()))
+			span.SetAttribute("endpoint.method", string(ctxVal.GetMethod()))
+			span.SetAttribute("endpoint.path", ctxVal.GetPath())
+			if hasTr {
+				span.SetAttribute("transport.operation", tr.Operation())
+			}
+			if c, ok := ctx.(interface{ RemoteIP() string }); ok {
+				span.SetAttribute("peer.ip", c.RemoteIP())
+			}
+			if id, ok := ctxVal.Get(endpoint.RequestIDKey); ok {
+				span.SetAttribute("request_id", id)
+			}
+			ctxVal.Set(spanKey, span)
+
+			resp, err := next(ctx, request)
+
+			code := think.GetCode(err)
+			span.SetAttribute("think.code", int64(code))
+			if code.HttpCode() >= http2.StatusInternalServerError {
+				span.SetError(err.Error())
+			}
+			span.End()
+			return resp, err
+		}
+	}
+}
+
+// Extract reads the remote span context of a http header or grpc metadata.
+func Extract(h transport.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(HeaderTraceparent))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = h.Get(HeaderTracestate)
+	return sc, true
+}
+
+// Inject writes sc to a http header or grpc metadata.
+func Inject(sc SpanContext, h transport.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(HeaderTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(HeaderTracestate, sc.TraceState)
+	}
+}
+
+type httpHeader http2.Header
+
+func (h httpHeader) Get(key string) string { return http2.Header(h).Get(key) }
+func (h httpHeader) Set(key, value string) { http2.Header(h).Set(key, value) }
+func (h httpHeader) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type roundTripper struct {
+	tracer *Tracer
+	next   http2.RoundTripper
+}
+
+// RoundTripper starts a client span per outbound http request and sends its
+// traceparent, next defaults to http.DefaultTransport.
+func (t *Tracer) RoundTripper(next http2.RoundTripper) http2.RoundTripper {
+	if next == nil {
+		next = http2.DefaultTransport
+	}
+	return roundTripper{tracer: t, next: next}
+}
+
+func (rt roundTripper) RoundTrip(req *http2.Request) (*http2.Response, error) {
+	ctx, span := rt.tracer.Start(req.Context(), req.Method+" "+req.URL.Path, SpanKindClient)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Redacted())
+	req = req.Clone(ctx)
+	Inject(span.Ctx, httpHeader(req.Header))
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		span.SetError(err.Error())
+	} else {
+		span.SetAttribute("http.status_code", int64(resp.StatusCode))
+		if resp.StatusCode >= http2.StatusBadRequest {
+			span.SetError("http status " + strconv.Itoa(resp.StatusCode))
+		}
+	}
+	span.End()
+	return resp, err
+}
+
+// UnaryClientInterceptor starts a client span per outbound grpc call and sends its traceparent.
+func (t *Tracer) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := t.Start(ctx, method, SpanKindClient)
+		span.SetAttribute("rpc.system", "grpc")
+		span.SetAttribute("rpc.method", method)
+		ctx = metadata.AppendToOutgoingContext(ctx, HeaderTraceparent, span.Ctx.Traceparent())
+		if span.Ctx.TraceState != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, HeaderTracestate, span.Ctx.TraceState)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			span.SetAttribute("think.code", int64(think.GetCode(err)))
+			span.SetError(err.Error())
+		}
+		span.End()
+		return err
+	}
+}
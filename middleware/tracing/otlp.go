@@ -0,0 +1,327 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	http2 "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OTLPOption is an OTLP exporter option.
+type OTLPOption func(*OTLPExporter)
+
+// WithOTLPHeaders adds headers to every export request, like an api key.
+func WithOTLPHeaders(h map[string]string) OTLPOption {
+	return func(e *OTLPExporter) {
+		for k, v := range h {
+			e.headers[k] = v
+		}
+	}
+}
+
+// WithOTLPBatch exports once size spans are queued or every interval, default 512 and 5s.
+func WithOTLPBatch(size int, interval time.Duration) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.batchSize = size
+		e.interval = interval
+	}
+}
+
+// WithOTLPMaxQueue bounds the spans waiting for the collector, default 4
+// batches. Spans beyond it are dropped and counted, see Dropped.
+func WithOTLPMaxQueue(n int) OTLPOption {
+	return func(e *OTLPExporter) { e.maxQueue = n }
+}
+
+// WithOTLPClient sets the http client, default one with a 10s timeout.
+func WithOTLPClient(c *http2.Client) OTLPOption {
+	return func(e *OTLPExporter) { e.client = c }
+}
+
+// WithOTLPService names the service in the exported resource.
+func WithOTLPService(name string) OTLPOption {
+	return func(e *OTLPExporter) { e.service = name }
+}
+
+var _ Exporter = (*OTLPExporter)(nil)
+
+// OTLPExporter sends spans in batches to an OTLP/HTTP collector with the json encoding.
+type OTLPExporter struct {
+	dropped uint64 // first for 64-bit atomic alignment on 32-bit platforms
+
+	url       string
+	client    *http2.Client
+	headers   map[string]string
+	service   string
+	batchSize int
+	maxQueue  int
+	interval  time.Duration
+
+	lock    sync.Mutex
+	queue   []*Span
+	flushCh chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewOTLPExporter exports to endpoint, the collector base url like
+// http://127.0.0.1:4318, adding /v1/traces when no path is given.
+func NewOTLPExporter(endpoint string, opts ...OTLPOption) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if i := strings.Index(url, "://"); i < 0 || !strings.Contains(url[i+3:], "/") {
+		url += "/v1/traces"
+	}
+	e := &OTLPExporter{
+		url:       url,
+		client:    &http2.Client{Timeout: 10 * time.Second},
+		headers:   make(map[string]string),
+		service:   "unknown_service",
+		batchSize: 512,
+		interval:  5 * time.Second,
+		flushCh:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.maxQueue <= 0 {
+		e.maxQueue = 4 * e.batchSize
+	}
+	go e.loop()
+	return e
+}
+
+// ErrExporterStopped is returned by ExportSpans after Shutdown.
+var ErrExporterStopped = errors.New("tracing: exporter stopped")
+
+// ExportSpans queues spans for the next batch, dropping those beyond the
+// queue bound while the collector is slow or down.
+func (e *OTLPExporter) ExportSpans(_ context.Context, spans []*Span) error {
+	e.lock.Lock()
+	select {
+	case <-e.stop:
+		// the final flush of Shutdown may have run already
+		e.lock.Unlock()
+		atomic.AddUint64(&e.dropped, uint64(len(spans)))
+		return ErrExporterStopped
+	default:
+	}
+	if room := e.maxQueue - len(e.queue); len(spans) > room {
+		if room < 0 {
+			room = 0
+		}
+		atomic.AddUint64(&e.dropped, uint64(len(spans)-room))
+		spans = spans[:room]
+	}
+	e.queue = append(e.queue, spans...)
+	full := len(e.queue) >= e.batchSize
+	e.lock.Unlock()
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of spans dropped because the queue was full or
+// the exporter stopped.
+func (e *OTLPExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Shutdown sends the queued spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.Flush(ctx)
+}
+
+// Flush sends the queued spans now.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	e.lock.Lock()
+	spans := e.queue
+	e.queue = nil
+	e.lock.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return e.send(ctx, spans)
+}
+
+func (e *OTLPExporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.flushCh:
+		}
+		if err := e.Flush(context.Background()); err != nil {
+			log.Printf("[Tracing] otlp export err: %v", err)
+		}
+	}
+}
+
+func (e *OTLPExporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http2.NewRequestWithContext(ctx, http2.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: collector replied %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below follow the json mapping of the OTLP trace protobuf.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *OTLPExporter) encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		os := otlpSpan{
+			TraceID:           s.Ctx.TraceID.String(),
+			SpanID:            s.Ctx.SpanID.String(),
+			TraceState:        s.Ctx.TraceState,
+			Name:              s.Name,
+			Kind:              otlpKind(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.Start().UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes()),
+		}
+		if s.Parent.IsValid() {
+			os.ParentSpanID = s.Parent.String()
+		}
+		if errored, msg := s.Status(); errored {
+			os.Status = otlpStatus{Code: 2, Message: msg}
+		}
+		out = append(out, os)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": e.service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/zander-84/gull/middleware/tracing"}, Spans: out}},
+	}}}
+}
+
+// otlpKind maps to the SpanKind enum of OTLP.
+func otlpKind(k SpanKind) int {
+	switch k {
+	case SpanKindServer:
+		return 2
+	case SpanKindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var val otlpValue
+		switch t := v.(type) {
+		case string:
+			val.StringValue = &t
+		case bool:
+			val.BoolValue = &t
+		case int:
+			s := strconv.Itoa(t)
+			val.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(t, 10)
+			val.IntValue = &s
+		case float64:
+			val.DoubleValue = &t
+		default:
+			s := fmt.Sprint(t)
+			val.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: val})
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether t is not all zeros.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether s is not all zeros.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+// IsValid reports whether sc has both a trace and a span id.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+var errTraceparent = errors.New("tracing: invalid traceparent")
+
+// ParseTraceparent parses a W3C traceparent header value, versions above 00
+// are read by their first four fields as the spec requires.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, errTraceparent
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, errTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errTraceparent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, errTraceparent
+	}
+	if !sc.IsValid() {
+		return sc, errTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+// SpanKind tells what side of a call a span covers.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota + 1
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	tracer *Tracer
+
+	Name   string
+	Kind   SpanKind
+	Ctx    SpanContext
+	Parent SpanID
+
+	lock      sync.Mutex
+	start     time.Time
+	end       time.Time
+	attrs     map[string]interface{}
+	errored   bool
+	statusMsg string
+	ended     bool
+}
+
+// SetAttribute records a string, bool, int, int64 or float64 value on s.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{})
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks s as failed with msg.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errored = true
+	s.statusMsg = msg
+}
+
+// End finishes s and hands it to the exporter when it is sampled, later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.lock.Unlock()
+	if s.Ctx.Sampled && s.tracer != nil {
+		s.tracer.export(s)
+	}
+}
+
+// Start returns when s started.
+func (s *Span) Start() time.Time { return s.start }
+
+// EndTime returns when s ended, zero while it runs.
+func (s *Span) EndTime() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.end
+}
+
+// Attributes returns a copy of the attributes of s.
+func (s *Span) Attributes() map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	out := make(map[string]interface{}, len(s.attrs))
+	for k, v := range s.attrs {
+		out[k] = v
+	}
+	return out
+}
+
+// Status reports whether s failed and why.
+func (s *Span) Status() (errored bool, msg string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.errored, s.statusMsg
+}
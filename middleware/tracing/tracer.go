@@ -0,0 +1,136 @@
+// Package tracing creates spans for Rmc endpoints and outbound calls and
+// propagates them with the W3C traceparent and tracestate headers.
+//
+//	tracer := tracing.NewTracer(tracing.WithExporter(tracing.NewOTLPExporter("http://collector:4318", tracing.WithOTLPService("order"))))
+//	rmc.Use(endpoint.OptionsInterceptor(tracer.Middleware()))
+//	client := &http.Client{Transport: tracer.RoundTripper(nil)}
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/zander-84/gull/endpoint"
+	"log"
+	"time"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+
+	// spanKey is the CtxVal key holding the server span of the running endpoint.
+	spanKey = "tracing.span"
+)
+
+type spanCtxKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying s.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanCtxKey{}, s)
+}
+
+// SpanFromContext returns the innermost span of ctx, spans started inside a
+// handler come first and the server span of the endpoint after them.
+func SpanFromContext(ctx context.Context) *Span {
+	if s, ok := ctx.Value(spanCtxKey{}).(*Span); ok {
+		return s
+	}
+	if v, ok := endpoint.GetCtxVal(ctx); ok {
+		if s, ok := v.Get(spanKey); ok {
+			span, _ := s.(*Span)
+			return span
+		}
+	}
+	return nil
+}
+
+// Option is a tracer option.
+type Option func(*Tracer)
+
+// WithExporter sets where sampled spans go, default nowhere.
+func WithExporter(exp Exporter) Option {
+	return func(t *Tracer) { t.exporter = exp }
+}
+
+// WithSampleRatio samples the given fraction of new traces, default 1.
+// Spans with a parent follow the sampling decision of the parent.
+func WithSampleRatio(ratio float64) Option {
+	return func(t *Tracer) { t.ratio = ratio }
+}
+
+// Tracer starts spans.
+type Tracer struct {
+	exporter Exporter
+	ratio    float64
+}
+
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{ratio: 1}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Start starts a span as a child of the span in ctx, or of the remote parent
+// set by ContextWithRemote, and returns a context carrying it.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	var parent SpanContext
+	if s := SpanFromContext(ctx); s != nil {
+		parent = s.Ctx
+	} else if sc, ok := ctx.Value(remoteCtxKey{}).(SpanContext); ok {
+		parent = sc
+	}
+	s := t.newSpan(parent, name, kind)
+	return ContextWithSpan(ctx, s), s
+}
+
+func (t *Tracer) newSpan(parent SpanContext, name string, kind SpanKind) *Span {
+	s := &Span{tracer: t, Name: name, Kind: kind, start: time.Now()}
+	if parent.IsValid() {
+		s.Ctx.TraceID = parent.TraceID
+		s.Ctx.Sampled = parent.Sampled
+		s.Ctx.TraceState = parent.TraceState
+		s.Parent = parent.SpanID
+	} else {
+		s.Ctx.TraceID = newTraceID()
+		s.Ctx.Sampled = t.sample(s.Ctx.TraceID)
+	}
+	s.Ctx.SpanID = newSpanID()
+	return s
+}
+
+// sample decides by the trace id so every service sampling at the same ratio agrees.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(id[8:])>>1) < t.ratio*(1<<63)
+}
+
+func (t *Tracer) export(s *Span) {
+	if t.exporter == nil {
+		return
+	}
+	if err := t.exporter.ExportSpans(context.Background(), []*Span{s}); err != nil {
+		log.Printf("[Tracing] export err: %v", err)
+	}
+}
+
+// Shutdown flushes and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+type remoteCtxKey struct{}
+
+// ContextWithRemote returns a copy of ctx whose next span continues the remote sc.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteCtxKey{}, sc)
+}
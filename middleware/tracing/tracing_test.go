@@ -0,0 +1,198 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"github.com/zander-84/gull/transport/http"
+	"io"
+	http2 "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent(parent)
+	if err != nil || !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("sc = %+v, err = %v", sc, err)
+	}
+	if sc.Traceparent() != parent {
+		t.Fatalf("round trip = %s", sc.Traceparent())
+	}
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Fatalf("%q should not parse", bad)
+		}
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Fatal("future versions may carry more fields")
+	}
+}
+
+func TestServerAndClient(t *testing.T) {
+	rec := NewRecorder()
+	tracer := NewTracer(WithExporter(rec))
+
+	var sent string
+	downstream := httptest.NewServer(http2.HandlerFunc(func(w http2.ResponseWriter, r *http2.Request) {
+		sent = r.Header.Get(HeaderTraceparent)
+	}))
+	defer downstream.Close()
+	client := &http2.Client{Transport: tracer.RoundTripper(nil)}
+
+	h := tracer.Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, _ := http2.NewRequestWithContext(ctx, http2.MethodGet, downstream.URL+"/items", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return nil, think.New(think.CodeUnavailable, "", "down", "")
+	})
+
+	req := httptest.NewRequest(http2.MethodGet, "/orders", nil)
+	req.Header.Set(HeaderTraceparent, parent)
+	req.Header.Set(HeaderTracestate, "k=v")
+	ctxVal := endpoint.NewCtxVal()
+	ctxVal.SetRoute(endpoint.MethodGet, "/orders")
+	req = req.WithContext(endpoint.WithContext(req.Context(), ctxVal))
+	_, _ = h(http.NewHttpContext(httptest.NewRecorder(), req), nil)
+
+	spans := rec.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	client0, server := spans[0], spans[1]
+	if server.Kind != SpanKindServer || server.Name != "GET /orders" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span %+v", server)
+	}
+	if server.Ctx.TraceState != "k=v" || server.Ctx.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatal("server span should continue the remote trace")
+	}
+	if errored, _ := server.Status(); !errored || server.Attributes()["think.code"] != int64(think.CodeUnavailable) {
+		t.Fatalf("server status %v, attributes %v", errored, server.Attributes())
+	}
+	if client0.Kind != SpanKindClient || client0.Parent != server.Ctx.SpanID || client0.Ctx.TraceID != server.Ctx.TraceID {
+		t.Fatalf("client span %+v", client0)
+	}
+	if sent != client0.Ctx.Traceparent() {
+		t.Fatalf("sent %s, want %s", sent, client0.Ctx.Traceparent())
+	}
+}
+
+func TestClientErrorIsNotServerFault(t *testing.T) {
+	rec := NewRecorder()
+	h := NewTracer(WithExporter(rec)).Middleware()(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, think.New(think.CodeParamError, "", "bad", "")
+	})
+	_, _ = h(endpoint.WithContext(context.Background(), endpoint.NewCtxVal()), nil)
+	if errored, _ := rec.Spans()[0].Status(); errored {
+		t.Fatal("client faults should not fail the server span")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	rec := NewRecorder()
+	tracer := NewTracer(WithExporter(rec), WithSampleRatio(0))
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindInternal)
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.End()
+	root.End()
+	if root.Ctx.Sampled || len(rec.Spans()) != 0 {
+		t.Fatal("unsampled spans should not be exported")
+	}
+
+	sc, _ := ParseTraceparent(parent)
+	_, span := tracer.Start(ContextWithRemote(context.Background(), sc), "remote", SpanKindInternal)
+	span.End()
+	if len(rec.Spans()) != 1 {
+		t.Fatal("the sampled flag of the parent should win")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	got := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http2.HandlerFunc(func(w http2.ResponseWriter, r *http2.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Api-Key") != "k" {
+			w.WriteHeader(http2.StatusBadRequest)
+			return
+		}
+		var req otlpRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got <- req
+	}))
+	defer collector.Close()
+
+	exp := NewOTLPExporter(collector.URL, WithOTLPHeaders(map[string]string{"Api-Key": "k"}), WithOTLPService("order"), WithOTLPBatch(10, time.Hour))
+	tracer := NewTracer(WithExporter(exp))
+	_, span := tracer.Start(context.Background(), "work", SpanKindServer)
+	span.SetAttribute("n", 3)
+	span.SetError("boom")
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-got
+	rs := req.ResourceSpans[0]
+	if *rs.Resource.Attributes[0].Value.StringValue != "order" {
+		t.Fatal("service name missing")
+	}
+	s := rs.ScopeSpans[0].Spans[0]
+	if s.Name != "work" || s.Kind != 2 || s.Status.Code != 2 || s.TraceID != span.Ctx.TraceID.String() || *s.Attributes[0].Value.IntValue != "3" {
+		t.Fatalf("unexpected span %+v", s)
+	}
+}
+
+func TestOTLPQueueBound(t *testing.T) {
+	block := make(chan struct{})
+	collector := httptest.NewServer(http2.HandlerFunc(func(w http2.ResponseWriter, r *http2.Request) {
+		<-block
+	}))
+	defer collector.Close()
+	defer close(block)
+
+	exp := NewOTLPExporter(collector.URL, WithOTLPBatch(2, time.Hour), WithOTLPMaxQueue(3))
+	spans := func(n int) []*Span {
+		out := make([]*Span, n)
+		for i := range out {
+			out[i] = &Span{Name: "s"}
+		}
+		return out
+	}
+	// the first batch is taken by a send stuck on the collector
+	_ = exp.ExportSpans(context.Background(), spans(2))
+	deadline := time.Now().Add(time.Second)
+	for {
+		exp.lock.Lock()
+		n := len(exp.queue)
+		exp.lock.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the batch was not sent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_ = exp.ExportSpans(context.Background(), spans(5))
+	if exp.Dropped() != 2 {
+		t.Fatalf("dropped = %d, want 2", exp.Dropped())
+	}
+
+	exp.once.Do(func() { close(exp.stop) })
+	if err := exp.ExportSpans(context.Background(), spans(1)); err != ErrExporterStopped || exp.Dropped() != 3 {
+		t.Fatalf("err = %v, dropped = %d, spans after Shutdown should be refused", err, exp.Dropped())
+	}
+}
@@ -13,12 +13,34 @@ type ServiceDiscovery struct {
 	watcher  Watcher
 }
 
-func NewServiceDiscovery(serviceName string, d Discovery, p lb.Policy) (*ServiceDiscovery, error) {
+// DiscoveryOption is a service discovery option.
+type DiscoveryOption func(*discoveryOptions)
+
+type discoveryOptions struct {
+	wrap []func(lb.Balancer) lb.Balancer
+}
+
+// WithBalancer wraps the balancer, like metrics.Balancer does.
+func WithBalancer(wrap func(lb.Balancer) lb.Balancer) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		o.wrap = append(o.wrap, wrap)
+	}
+}
+
+func NewServiceDiscovery(serviceName string, d Discovery, p lb.Policy, opts ...DiscoveryOption) (*ServiceDiscovery, error) {
+	var o discoveryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	var err error
 	sd := new(ServiceDiscovery)
 	sd.d = d
 	sd.listener = lb.NewListener(serviceName)
 	sd.lb = lb.NewBalancer(sd.listener, p, false)
+	for _, wrap := range o.wrap {
+		sd.lb = wrap(sd.lb)
+	}
 	sd.watcher, err = sd.d.Watch(context.Background(), serviceName)
 	if err != nil {
 		return nil, err
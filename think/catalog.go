@@ -0,0 +1,253 @@
+package think
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the locale of Code.ToString and the fallback of Code.Message.
+var DefaultLocale = "zh"
+
+// CodeInfo describes a code of the catalogue, usually loaded from json:
+//
+//	[{"code": 200404, "http": 404, "grpc": 5, "messages": {"zh": "订单不存在", "en": "order not found"}}]
+type CodeInfo struct {
+	Code Code `json:"code"`
+	// HTTP is the status of errors with this code, 0 means 500.
+	HTTP int `json:"http"`
+	// GRPC is the status code sent on the wire, 0 sends the think code itself.
+	GRPC codes.Code `json:"grpc"`
+	// Messages holds the message per locale, like "zh" or "en-US".
+	Messages map[string]string `json:"messages"`
+	// Retryable marks errors with this code as safe to retry, see IsRetryable.
+	Retryable bool `json:"retryable"`
+}
+
+type catalog struct {
+	lock  sync.RWMutex
+	codes map[Code]*CodeInfo
+}
+
+var defaultCatalog = &catalog{codes: make(map[Code]*CodeInfo)}
+
+// Register adds codes to the catalogue. An existing code keeps the fields
+// left zero and gains the new messages.
+func Register(infos ...CodeInfo) {
+	defaultCatalog.lock.Lock()
+	defer defaultCatalog.lock.Unlock()
+	for _, info := range infos {
+		cur, ok := defaultCatalog.codes[info.Code]
+		if !ok {
+			cur = &CodeInfo{Code: info.Code, Messages: make(map[string]string)}
+			defaultCatalog.codes[info.Code] = cur
+		}
+		if info.HTTP != 0 {
+			cur.HTTP = info.HTTP
+		}
+		if info.GRPC != 0 {
+			cur.GRPC = info.GRPC
+		}
+		if info.Retryable {
+			cur.Retryable = true
+		}
+		for locale, msg := range info.Messages {
+			cur.Messages[strings.ToLower(locale)] = msg
+		}
+	}
+}
+
+// LoadFile registers the codes of a json file, see CodeInfo.
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var infos []CodeInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return fmt.Errorf("think: load %s: %w", path, err)
+	}
+	Register(infos...)
+	return nil
+}
+
+// Lookup returns a copy of the catalogue entry of code.
+func Lookup(code Code) (CodeInfo, bool) {
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	cur, ok := defaultCatalog.codes[code]
+	if !ok {
+		return CodeInfo{}, false
+	}
+	out := *cur
+	out.Messages = make(map[string]string, len(cur.Messages))
+	for k, v := range cur.Messages {
+		out.Messages[k] = v
+	}
+	return out, true
+}
+
+// Locales returns the locales with at least one message, sorted.
+func Locales() []string {
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	seen := make(map[string]bool)
+	for _, info := range defaultCatalog.codes {
+		for locale := range info.Messages {
+			seen[locale] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for locale := range seen {
+		out = append(out, locale)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Message returns the message of c in locale, falling back to its base
+// language, then DefaultLocale.
+func (c Code) Message(locale string) string {
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	if info, ok := defaultCatalog.codes[c]; ok {
+		locale = strings.ToLower(locale)
+		if msg, ok := info.Messages[locale]; ok {
+			return msg
+		}
+		if i := strings.IndexByte(locale, '-'); i > 0 {
+			if msg, ok := info.Messages[locale[:i]]; ok {
+				return msg
+			}
+		}
+		if msg, ok := info.Messages[DefaultLocale]; ok {
+			return msg
+		}
+	}
+	return fmt.Sprintf("未定义： %d ", c)
+}
+
+// isCatalogMessage reports whether msg is one of the catalogue messages of c,
+// so it can be swapped for another locale.
+func (c Code) isCatalogMessage(msg string) bool {
+	if msg == "" || msg == fmt.Sprintf("未定义： %d ", c) {
+		return true
+	}
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	if info, ok := defaultCatalog.codes[c]; ok {
+		for _, m := range info.Messages {
+			if m == msg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c Code) grpcCode() codes.Code {
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	if info, ok := defaultCatalog.codes[c]; ok && info.GRPC != 0 {
+		return info.GRPC
+	}
+	return codes.Code(c)
+}
+
+// Localize returns err with the message of its code in locale. Messages set
+// by the caller which are not from the catalogue are kept.
+func Localize(err error, locale string) *Error {
+	e := FromError(err)
+	if e == nil || locale == "" || !e.Code.isCatalogMessage(e.Message) {
+		return e
+	}
+	out := Clone(e)
+	out.Message = e.Code.Message(locale)
+	if m, ok := e.cause.(*MultiError); ok {
+		out.Data = m.localize(locale)
+	}
+	return out
+}
+
+// Envelope returns the http status and the json body err is answered with:
+// the Response of err with the catalogue message in the locale best matching
+// acceptLanguage. transport/http.EncodeError, the default error encoder of
+// endpoint and the misses of the http Router all write it.
+func Envelope(err error, acceptLanguage string) (int, Response) {
+	e := Localize(err, MatchLocale(acceptLanguage))
+	if e == nil {
+		return http.StatusOK, Response{Code: CodeSuccess, Message: CodeSuccess.ToString()}
+	}
+	return e.Code.HttpCode(), e.Response
+}
+
+// MatchLocale picks the catalogue locale best matching an Accept-Language
+// header, or "" when none does.
+func MatchLocale(acceptLanguage string) string {
+	type tag struct {
+		locale string
+		q      float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{locale: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				if q, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					t.q = q
+				}
+			}
+		}
+		if t.locale != "" && t.locale != "*" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	known := make(map[string]bool)
+	for _, l := range Locales() {
+		known[l] = true
+	}
+	for _, t := range tags {
+		if known[t.locale] {
+			return t.locale
+		}
+		if i := strings.IndexByte(t.locale, '-'); i > 0 && known[t.locale[:i]] {
+			return t.locale[:i]
+		}
+	}
+	return ""
+}
+
+func init() {
+	Register(
+		CodeInfo{Code: CodeSuccess, HTTP: http.StatusOK, Messages: map[string]string{"zh": "成功", "en": "success"}},
+		CodeInfo{Code: CodeSuccessAction, HTTP: http.StatusCreated, Messages: map[string]string{"zh": "成功", "en": "success"}},
+		CodeInfo{Code: CodeBizError, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "业务错误", "en": "business error"}},
+		CodeInfo{Code: CodeAlterError, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "提示错误", "en": "alert"}},
+		CodeInfo{Code: CodeParamError, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "参数错误", "en": "invalid parameter"}},
+		CodeInfo{Code: CodeNotFound, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "404", "en": "not found"}},
+		CodeInfo{Code: CodeRepeat, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "重复操作", "en": "duplicate operation"}},
+		CodeInfo{Code: CodeMethodNotAllowed, HTTP: http.StatusMethodNotAllowed, Messages: map[string]string{"zh": "请求方法不允许", "en": "method not allowed"}},
+		CodeInfo{Code: CodeUnDone, HTTP: http.StatusBadRequest, Messages: map[string]string{"zh": "未完成", "en": "not done"}},
+		CodeInfo{Code: CodeForbidden, HTTP: http.StatusForbidden, Messages: map[string]string{"zh": "禁止访问", "en": "forbidden"}},
+		CodeInfo{Code: CodeSignError, HTTP: http.StatusForbidden, Messages: map[string]string{"zh": "签名错误", "en": "invalid signature"}},
+		CodeInfo{Code: CodeUnauthorized, HTTP: http.StatusUnauthorized, Messages: map[string]string{"zh": "未认证", "en": "unauthorized"}},
+		CodeInfo{Code: CodeTooManyRequests, HTTP: http.StatusTooManyRequests, Messages: map[string]string{"zh": "反问过于频繁", "en": "too many requests"}, Retryable: true},
+		CodeInfo{Code: CodeSystemSpaceError, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "系统空间错误", "en": "system error"}},
+		CodeInfo{Code: CodePanicError, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "服务内部错误", "en": "internal server error"}},
+		CodeInfo{Code: CodeIgnore, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "忽略", "en": "ignored"}},
+		CodeInfo{Code: CodeUndefined, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "未定义", "en": "undefined"}},
+		CodeInfo{Code: CodeTimeOut, HTTP: http.StatusGatewayTimeout, Messages: map[string]string{"zh": "超时", "en": "timeout"}, Retryable: true},
+		CodeInfo{Code: CodeException, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "异常", "en": "exception"}},
+		CodeInfo{Code: CodeTypeError, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "错误类型", "en": "type error"}},
+		CodeInfo{Code: CodeUnavailable, HTTP: http.StatusInternalServerError, Messages: map[string]string{"zh": "不可达", "en": "unavailable"}, Retryable: true},
+	)
+}
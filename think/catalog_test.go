@@ -0,0 +1,79 @@
+package think
+
+import (
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinCodes(t *testing.T) {
+	if CodeNotFound.ToString() != "404" || CodeSuccessAction.HttpCode() != 201 || CodePanicError.HttpCode() != 500 {
+		t.Fatal("builtin codes should keep their messages and statuses")
+	}
+	if Code(999999).HttpCode() != 500 || Code(999999).ToString() != "未定义： 999999 " {
+		t.Fatal("unknown codes should stay undefined")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codes.json")
+	data := `[{"code": 200404, "http": 404, "grpc": 5, "messages": {"zh": "订单不存在", "en": "order not found", "en-GB": "no such order"}}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	code := Code(200404)
+	if code.HttpCode() != 404 || code.ToString() != "订单不存在" {
+		t.Fatalf("http = %d, message = %s", code.HttpCode(), code.ToString())
+	}
+	for locale, want := range map[string]string{"en": "order not found", "en-US": "order not found", "EN-gb": "no such order", "fr": "订单不存在"} {
+		if got := code.Message(locale); got != want {
+			t.Fatalf("%s: %s, want %s", locale, got, want)
+		}
+	}
+
+	// the mapped grpc code is sent on the wire and the think code still comes back
+	gs, _ := status.FromError(New(code, "", code.ToString(), ""))
+	if gs.Code() != codes.NotFound {
+		t.Fatalf("grpc code = %v", gs.Code())
+	}
+	if got := FromError(gs.Err()); got.Code != code || len(got.Metadata) != 0 {
+		t.Fatalf("got %+v", got.Response)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	if got := MatchLocale("fr-CH, fr;q=0.9, en-US;q=0.8, *;q=0.5"); got != "en" {
+		t.Fatalf("match = %s, want en", got)
+	}
+	if got := MatchLocale("fr"); got != "" {
+		t.Fatalf("match = %s, want none", got)
+	}
+
+	e := Localize(New(CodeParamError, "B1", CodeParamError.ToString(), "name"), "en")
+	if e.Message != "invalid parameter" || e.BizCode != "B1" || e.Data != "name" {
+		t.Fatalf("got %+v", e.Response)
+	}
+	if e = Localize(New(CodeParamError, "", "name is required", ""), "en"); e.Message != "name is required" {
+		t.Fatal("custom messages should be kept")
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	status, body := Envelope(New(CodeParamError, "B1", CodeParamError.ToString(), "name"), "fr, en;q=0.5")
+	if status != 400 || body.Code != CodeParamError || body.Message != "invalid parameter" || body.Data != "name" {
+		t.Fatalf("got %d %+v", status, body)
+	}
+	if status, body = Envelope(errors.New("db down"), ""); status != 500 || body.Code != CodeUndefined {
+		t.Fatalf("a plain error: %d %+v", status, body)
+	}
+	if status, body = Envelope(nil, ""); status != 200 || body.Code != CodeSuccess {
+		t.Fatalf("no error: %d %+v", status, body)
+	}
+}
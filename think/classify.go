@@ -0,0 +1,53 @@
+package think
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// IsRetryable reports whether the call failing with err may succeed when sent
+// again: timeouts and codes registered with Retryable, like CodeUnavailable and
+// CodeTooManyRequests. Cancellation by the caller is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsTimeout(err) {
+		return true
+	}
+	info, ok := Lookup(GetCode(err))
+	return ok && info.Retryable
+}
+
+// IsClientFault reports whether err is caused by the request, i.e. its code
+// maps to a 4xx http status.
+func IsClientFault(err error) bool {
+	if err == nil {
+		return false
+	}
+	status := GetCode(err).HttpCode()
+	return status >= 400 && status < 500
+}
+
+// IsServerFault reports whether err is caused by the server or an upstream,
+// i.e. its code maps to a 5xx http status. Plain errors are server faults.
+func IsServerFault(err error) bool {
+	return err != nil && GetCode(err).HttpCode() >= 500
+}
+
+// IsTimeout reports whether err is CodeTimeOut, a deadline exceeded or a
+// network timeout.
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return GetCode(err) == CodeTimeOut
+}
@@ -0,0 +1,79 @@
+package think
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedHelpers(t *testing.T) {
+	err := ErrNotFound("order 7")
+	if !IsErrNotFound(err) || IsErrParam(err) {
+		t.Fatal("predicates should match the code only")
+	}
+	if e := FromError(err); e.Message != CodeNotFound.ToString() || e.Data != "order 7" {
+		t.Fatalf("got %+v", e.Response)
+	}
+	if !IsErrParam(fmt.Errorf("wrapped: %w", ErrParam("name"))) {
+		t.Fatal("predicates should see wrapped errors")
+	}
+}
+
+func TestStack(t *testing.T) {
+	err := ErrTimeOut("slow")
+	if s := fmt.Sprintf("%v", err); strings.Contains(s, "\n") {
+		t.Fatalf("%%v should not print the stack: %s", s)
+	}
+	s := fmt.Sprintf("%+v", err)
+	lines := strings.Split(s, "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[1], "think.TestStack") {
+		t.Fatalf("the stack should start at the caller:\n%s", s)
+	}
+	if fmt.Sprintf("%+v", Clone(FromError(err))) != s {
+		t.Fatal("Clone should keep the stack")
+	}
+	if s := fmt.Sprintf("%d", err); s != "%!d(think.Error="+err.Error()+")" {
+		t.Fatalf("other verbs should print the error: %s", s)
+	}
+}
+
+type netTimeout struct{}
+
+func (netTimeout) Error() string   { return "i/o timeout" }
+func (netTimeout) Timeout() bool   { return true }
+func (netTimeout) Temporary() bool { return true }
+
+var _ net.Error = netTimeout{}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err                                error
+		retryable, client, server, timeout bool
+	}{
+		{nil, false, false, false, false},
+		{ErrTimeOut(""), true, false, true, true},
+		{ErrUnavailable(""), true, false, true, false},
+		{ErrTooManyRequests(""), true, true, false, false},
+		{ErrParam(""), false, true, false, false},
+		{ErrPanic(""), false, false, true, false},
+		{context.DeadlineExceeded, true, false, true, true},
+		{context.Canceled, false, false, true, false},
+		{&net.OpError{Op: "dial", Err: netTimeout{}}, true, false, true, true},
+		{errors.New("plain"), false, false, true, false},
+	}
+	for _, c := range cases {
+		if IsRetryable(c.err) != c.retryable || IsClientFault(c.err) != c.client ||
+			IsServerFault(c.err) != c.server || IsTimeout(c.err) != c.timeout {
+			t.Fatalf("%v: retryable %v, client %v, server %v, timeout %v", c.err,
+				IsRetryable(c.err), IsClientFault(c.err), IsServerFault(c.err), IsTimeout(c.err))
+		}
+	}
+
+	Register(CodeInfo{Code: 200409, HTTP: 409, Retryable: true})
+	if !IsRetryable(New(200409, "", "", "")) || !IsClientFault(New(200409, "", "", "")) {
+		t.Fatal("registered codes should be classified by the catalogue")
+	}
+}
@@ -1,10 +1,11 @@
 package think
 
 import (
-	"fmt"
 	"net/http"
 )
 
+//go:generate go run gen_codes.go
+
 type Code uint32
 
 const (
@@ -24,6 +25,8 @@ const (
 
 	CodeRepeat Code = 100405 // 重复操作, 表示已存在
 
+	CodeMethodNotAllowed Code = 101405 // 请求方法不允许
+
 	CodeUnDone Code = 103400 // 记录错误
 
 	CodeForbidden Code = 100403 // 禁止访问
@@ -52,77 +55,17 @@ const (
 
 )
 
+// HttpCode returns the http status registered for c, 500 for unknown codes.
 func (c Code) HttpCode() int {
-	switch c {
-	case CodeSuccess:
-		return http.StatusOK
-	case CodeSuccessAction:
-		return http.StatusCreated
-	case CodeParamError, CodeNotFound, CodeAlterError, CodeBizError, CodeUnDone, CodeRepeat:
-		return http.StatusBadRequest
-
-	case CodeForbidden, CodeSignError:
-		return http.StatusForbidden
-
-	case CodeUnauthorized:
-		return http.StatusUnauthorized
-
-	case CodeTooManyRequests:
-		return http.StatusTooManyRequests
-
-	case CodeSystemSpaceError, CodeIgnore, CodeUndefined, CodeException, CodeTypeError, CodeUnavailable:
-		return http.StatusInternalServerError
-
-	case CodeTimeOut:
-		return http.StatusGatewayTimeout
-
-	default:
-		return http.StatusInternalServerError
-
+	defaultCatalog.lock.RLock()
+	defer defaultCatalog.lock.RUnlock()
+	if info, ok := defaultCatalog.codes[c]; ok && info.HTTP != 0 {
+		return info.HTTP
 	}
+	return http.StatusInternalServerError
 }
 
+// ToString returns the message of c in DefaultLocale, see Message.
 func (c Code) ToString() string {
-	switch c {
-	case CodeSuccess, CodeSuccessAction:
-		return "成功"
-	case CodeParamError:
-		return "参数错误"
-	case CodeNotFound:
-		return "404"
-	case CodeRepeat:
-		return "重复操作"
-	case CodeAlterError:
-		return "提示错误"
-	case CodeBizError:
-		return "业务错误"
-	case CodeUnDone:
-		return "未完成"
-	case CodeForbidden:
-		return "禁止访问"
-	case CodeSignError:
-		return "签名错误"
-	case CodeUnauthorized:
-		return "未认证"
-	case CodeTooManyRequests:
-		return "反问过于频繁"
-	case CodeSystemSpaceError:
-		return "系统空间错误"
-	case CodeIgnore:
-		return "忽略"
-	case CodeUndefined:
-		return "未定义"
-	case CodeTimeOut:
-		return "超时"
-	case CodeException:
-		return "异常"
-	case CodeTypeError:
-		return "错误类型"
-	case CodeUnavailable:
-		return "不可达"
-	case CodePanicError:
-		return "服务内部错误"
-	default:
-		return fmt.Sprintf("未定义： %d ", c)
-	}
+	return c.Message(DefaultLocale)
 }
@@ -9,6 +9,7 @@ import (
 type Error struct {
 	Response
 	cause error
+	stack []uintptr
 }
 
 // Unwrap provides compatibility for Go 1.13 error chains.
@@ -42,6 +43,11 @@ func (e *Error) WithMetadata(md map[string]string) *Error {
 
 // New returns an error object for the code, message.
 func New(code Code, bizCode string, message, reason string) *Error {
+	return newError(code, bizCode, message, reason)
+}
+
+// newError records the stack of the caller of New or of a generated helper.
+func newError(code Code, bizCode string, message, reason string) *Error {
 	return &Error{
 		Response: Response{
 			Code:    code,
@@ -49,6 +55,7 @@ func New(code Code, bizCode string, message, reason string) *Error {
 			Message: message,
 			Data:    reason,
 		},
+		stack: callers(4),
 	}
 }
 
@@ -63,8 +70,10 @@ func Clone(err *Error) *Error {
 	}
 	return &Error{
 		cause: err.cause,
+		stack: err.stack,
 		Response: Response{
 			Code:     err.Code,
+			BizCode:  err.BizCode,
 			Data:     err.Data,
 			Message:  err.Message,
 			Metadata: metadata,
@@ -81,29 +90,29 @@ func GetCode(err error) Code {
 	return FromError(err).Code
 }
 
+// thinkError is implemented by *Error and by the errors collapsing to one,
+// so FromError stops at whichever comes first in the chain.
+type thinkError interface {
+	thinkError() *Error
+}
+
+func (e *Error) thinkError() *Error {
+	return e
+}
+
 // FromError try to convert an error to *Error.
 // It supports wrapped errors.
 func FromError(err error) *Error {
 	if err == nil {
 		return nil
 	}
-	if se := new(Error); errors.As(err, &se) {
-		return se
+	var te thinkError
+	if errors.As(err, &te) {
+		return te.thinkError()
 	}
 	gs, ok := status.FromError(err)
 	if !ok {
 		return New(CodeUndefined, "", CodeUndefined.ToString(), err.Error())
 	}
-
-	if uint32(gs.Code()) < uint32(MinCode) {
-		return New(CodeSystemSpaceError, "", CodeSystemSpaceError.ToString(), gs.Message())
-	} else {
-		c := Code(gs.Code())
-		if c == CodeBizError {
-			return New(CodeSystemSpaceError, "", CodeSystemSpaceError.ToString(), gs.Message()) // todo
-		} else {
-			return New(c, "", c.ToString(), gs.Message())
-		}
-	}
-
+	return fromStatus(gs)
 }
@@ -0,0 +1,57 @@
+//go:build ignore
+
+// gen_codes writes types.go, a constructor and a predicate for every error
+// code declared in code.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+var skip = map[string]bool{"MinCode": true, "CodeSuccess": true, "CodeSuccessAction": true}
+
+func main() {
+	f, err := parser.ParseFile(token.NewFileSet(), "code.go", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_codes.go; DO NOT EDIT.\n\npackage think\n")
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for _, ident := range vs.Names {
+				if skip[ident.Name] {
+					continue
+				}
+				name := strings.TrimSuffix(strings.TrimPrefix(ident.Name, "Code"), "Error")
+				desc := strings.TrimSpace(vs.Comment.Text())
+				fmt.Fprintf(&buf, "\n// Err%s returns a %s error (%s) with reason as its data.\n", name, ident.Name, desc)
+				fmt.Fprintf(&buf, "func Err%s(reason string) error {\n\treturn newError(%s, \"\", %s.ToString(), reason)\n}\n", name, ident.Name, ident.Name)
+				fmt.Fprintf(&buf, "\n// IsErr%s reports whether the code of err is %s.\n", name, ident.Name)
+				fmt.Fprintf(&buf, "func IsErr%s(err error) bool {\n\treturn GetCode(err) == %s\n}\n", name, ident.Name)
+			}
+		}
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("types.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,151 @@
+package think
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"strconv"
+)
+
+// ErrorDomain marks the ErrorInfo details written by GRPCStatus.
+const ErrorDomain = "gull"
+
+// codeKey is the ErrorInfo metadata key of the think code when CodeInfo.GRPC maps it.
+const codeKey = "gull.code"
+
+// GRPCStatus lets grpc send e as a status whose code is the think.Code, or
+// the CodeInfo.GRPC mapping of it, with
+// an ErrorInfo detail holding BizCode as reason and Metadata, and Data as a
+// google.protobuf.Value detail when it can be encoded as json. The items of a
+// MultiError are also sent as BadRequest field violations.
+func (e *Error) GRPCStatus() *status.Status {
+	s := status.New(e.Code.grpcCode(), e.Message)
+	info := &errdetails.ErrorInfo{Reason: e.BizCode, Domain: ErrorDomain, Metadata: e.Metadata}
+	if c := e.Code.grpcCode(); c != codes.Code(e.Code) {
+		// a mapped grpc code loses the think code, which travels in the metadata instead
+		md := make(map[string]string, len(e.Metadata)+1)
+		for k, v := range e.Metadata {
+			md[k] = v
+		}
+		md[codeKey] = strconv.FormatUint(uint64(e.Code), 10)
+		info.Metadata = md
+	}
+	details := []proto.Message{info}
+	if v, ok := dataValue(e.Data); ok {
+		details = append(details, v)
+	}
+	if items, ok := e.Data.([]FieldError); ok {
+		br := &errdetails.BadRequest{}
+		for _, item := range items {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: item.Field, Description: item.Message})
+		}
+		details = append(details, br)
+	}
+	ds, err := s.WithDetails(details...)
+	if err != nil {
+		return s
+	}
+	return ds
+}
+
+func dataValue(data interface{}) (*structpb.Value, bool) {
+	if data == nil {
+		return nil, false
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+	var plain interface{}
+	if err = json.Unmarshal(raw, &plain); err != nil {
+		return nil, false
+	}
+	v, err := structpb.NewValue(plain)
+	return v, err == nil
+}
+
+// fromStatus rebuilds the Error sent by GRPCStatus. Statuses from other
+// servers keep their message as Data and get the closest Code.
+func fromStatus(gs *status.Status) *Error {
+	var info *errdetails.ErrorInfo
+	var data *structpb.Value
+	var br *errdetails.BadRequest
+	for _, d := range gs.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Domain == ErrorDomain {
+				info = d
+			}
+		case *structpb.Value:
+			data = d
+		case *errdetails.BadRequest:
+			br = d
+		}
+	}
+
+	code := codeFromGRPC(gs.Code())
+	if info == nil {
+		e := New(code, "", code.ToString(), gs.Message())
+		if br != nil {
+			e.Data = violations(code, br)
+		}
+		return e
+	}
+	md := info.Metadata
+	if v, ok := md[codeKey]; ok {
+		if c, err := strconv.ParseUint(v, 10, 32); err == nil {
+			code = Code(c)
+		}
+		delete(md, codeKey)
+	}
+	e := New(code, info.Reason, gs.Message(), "")
+	if len(md) > 0 {
+		e.Metadata = md
+	}
+	if data != nil {
+		e.Data = data.AsInterface()
+	} else if br != nil {
+		e.Data = violations(code, br)
+	}
+	return e
+}
+
+// violations turns the field violations of a BadRequest into the items of a
+// MultiError, all of them with the status code.
+func violations(code Code, br *errdetails.BadRequest) []FieldError {
+	out := make([]FieldError, 0, len(br.FieldViolations))
+	for _, v := range br.FieldViolations {
+		out = append(out, FieldError{Field: v.Field, Response: Response{Code: code, Message: v.Description}})
+	}
+	return out
+}
+
+// codeFromGRPC maps a status code to a Code, think codes pass through.
+func codeFromGRPC(c codes.Code) Code {
+	if uint32(c) >= uint32(MinCode) {
+		return Code(c)
+	}
+	switch c {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return CodeParamError
+	case codes.NotFound:
+		return CodeNotFound
+	case codes.AlreadyExists:
+		return CodeRepeat
+	case codes.PermissionDenied:
+		return CodeForbidden
+	case codes.Unauthenticated:
+		return CodeUnauthorized
+	case codes.ResourceExhausted:
+		return CodeTooManyRequests
+	case codes.DeadlineExceeded:
+		return CodeTimeOut
+	case codes.Unavailable:
+		return CodeUnavailable
+	default:
+		return CodeSystemSpaceError
+	}
+}
@@ -0,0 +1,74 @@
+package think
+
+import (
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"reflect"
+	"testing"
+)
+
+var allCodes = []Code{
+	CodeSuccess, CodeSuccessAction, CodeBizError, CodeAlterError, CodeParamError,
+	CodeNotFound, CodeRepeat, CodeMethodNotAllowed, CodeUnDone, CodeForbidden, CodeSignError,
+	CodeUnauthorized, CodeTooManyRequests, CodeSystemSpaceError, CodePanicError,
+	CodeIgnore, CodeUndefined, CodeTimeOut, CodeException, CodeTypeError, CodeUnavailable,
+}
+
+// wire sends err through the grpc status encoding as a server and client would.
+func wire(t *testing.T, err error) error {
+	gs, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("%v has no grpc status", err)
+	}
+	p := gs.Proto()
+	return status.ErrorProto(p)
+}
+
+func TestGRPCRoundTrip(t *testing.T) {
+	for _, code := range allCodes {
+		sent := New(code, "B"+code.ToString(), "msg", "reason").WithMetadata(map[string]string{"k": "v"})
+		got := FromError(wire(t, sent))
+		if got.Code != code || got.BizCode != sent.BizCode || got.Message != "msg" || got.Data != "reason" {
+			t.Fatalf("code %d: got %+v, want %+v", code, got.Response, sent.Response)
+		}
+		if !reflect.DeepEqual(got.Metadata, sent.Metadata) {
+			t.Fatalf("code %d: metadata %v, want %v", code, got.Metadata, sent.Metadata)
+		}
+	}
+}
+
+func TestGRPCData(t *testing.T) {
+	sent := New(CodeParamError, "", "invalid", "")
+	sent.Data = map[string]interface{}{"field": "name", "max": 3}
+	got := FromError(wire(t, sent))
+	want := map[string]interface{}{"field": "name", "max": float64(3)}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("data = %#v, want %#v", got.Data, want)
+	}
+
+	sent.Data = func() {}
+	if got = FromError(wire(t, sent)); got.Code != CodeParamError || got.Data != "" {
+		t.Fatalf("unencodable data should be dropped, got %+v", got.Response)
+	}
+}
+
+func TestForeignStatus(t *testing.T) {
+	cases := map[codes.Code]Code{
+		codes.NotFound:         CodeNotFound,
+		codes.InvalidArgument:  CodeParamError,
+		codes.Unauthenticated:  CodeUnauthorized,
+		codes.PermissionDenied: CodeForbidden,
+		codes.DeadlineExceeded: CodeTimeOut,
+		codes.Internal:         CodeSystemSpaceError,
+	}
+	for c, want := range cases {
+		got := FromError(status.Error(c, "upstream"))
+		if got.Code != want || got.Message != want.ToString() || got.Data != "upstream" {
+			t.Fatalf("%v: got %+v", c, got.Response)
+		}
+	}
+	if got := FromError(errors.New("plain")); got.Code != CodeUndefined {
+		t.Fatalf("plain errors are undefined, got %d", got.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package think
+
+import (
+	"encoding/json"
+	"errors"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+)
+
+// FieldError is an item of a MultiError, Field is a field name or an item index.
+type FieldError struct {
+	Field string
+	Response
+}
+
+// MultiError collects the failures of a validation or a batch, keyed by field
+// name or item index. The zero value is ready to use:
+//
+//	var errs think.MultiError
+//	if req.Name == "" {
+//		errs.Add("name", think.ErrParam("required"))
+//	}
+//	return errs.Err()
+//
+// FromError collapses it to the most severe code with the items as Data, and
+// grpc sends the items as BadRequest field violations as well.
+type MultiError struct {
+	fields []string
+	errs   []*Error
+}
+
+// Add records err under field, a nil err is ignored. The items of a nested
+// MultiError are added as "field.item".
+func (m *MultiError) Add(field string, err error) *MultiError {
+	if err == nil {
+		return m
+	}
+	var nested *MultiError
+	if errors.As(err, &nested) && nested != m {
+		for i, e := range nested.errs {
+			m.fields = append(m.fields, field+"."+nested.fields[i])
+			m.errs = append(m.errs, e)
+		}
+		return m
+	}
+	m.fields = append(m.fields, field)
+	m.errs = append(m.errs, FromError(err))
+	return m
+}
+
+// AddIndex records err under the index of a batch item.
+func (m *MultiError) AddIndex(i int, err error) *MultiError {
+	return m.Add(strconv.Itoa(i), err)
+}
+
+// Len returns the number of errors.
+func (m *MultiError) Len() int {
+	return len(m.errs)
+}
+
+// Get returns the first error of field, or nil.
+func (m *MultiError) Get(field string) *Error {
+	for i, f := range m.fields {
+		if f == field {
+			return m.errs[i]
+		}
+	}
+	return nil
+}
+
+// Errors returns the items in the order they were added.
+func (m *MultiError) Errors() []FieldError {
+	out := make([]FieldError, len(m.errs))
+	for i, e := range m.errs {
+		out[i] = FieldError{Field: m.fields[i], Response: e.Response}
+	}
+	return out
+}
+
+// Err returns m, or nil when nothing was added.
+func (m *MultiError) Err() error {
+	if m == nil || len(m.errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Code returns the most severe code, the one with the highest http status;
+// the first added wins a tie.
+func (m *MultiError) Code() Code {
+	if len(m.errs) == 0 {
+		return CodeSuccess
+	}
+	worst := m.errs[0].Code
+	for _, e := range m.errs[1:] {
+		if e.Code.HttpCode() > worst.HttpCode() {
+			worst = e.Code
+		}
+	}
+	return worst
+}
+
+func (m *MultiError) Error() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(len(m.errs)))
+	b.WriteString(" errors: ")
+	for i, e := range m.errs {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(m.fields[i])
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+		if reason, ok := e.Data.(string); ok && reason != "" {
+			b.WriteString(" (" + reason + ")")
+		}
+	}
+	return b.String()
+}
+
+// Is reports whether any of the errors matches target.
+func (m *MultiError) Is(target error) bool {
+	for _, e := range m.errs {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors matching target.
+func (m *MultiError) As(target interface{}) bool {
+	for _, e := range m.errs {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCStatus sends the collapsed error, see Error.GRPCStatus.
+func (m *MultiError) GRPCStatus() *status.Status {
+	return m.thinkError().GRPCStatus()
+}
+
+func (m *MultiError) thinkError() *Error {
+	code := m.Code()
+	return &Error{
+		Response: Response{Code: code, Message: code.ToString(), Data: m.Errors()},
+		cause:    m,
+	}
+}
+
+// localize returns the items with the catalogue messages in locale.
+func (m *MultiError) localize(locale string) []FieldError {
+	out := m.Errors()
+	for i := range out {
+		if out[i].Code.isCatalogMessage(out[i].Message) {
+			out[i].Message = out[i].Code.Message(locale)
+		}
+	}
+	return out
+}
+
+// FieldErrors returns the items of the MultiError in err, also when err was
+// decoded from a transport and only carries them as Data.
+func FieldErrors(err error) []FieldError {
+	var m *MultiError
+	if errors.As(err, &m) {
+		return m.Errors()
+	}
+	e := FromError(err)
+	if e == nil {
+		return nil
+	}
+	if items, ok := e.Data.([]FieldError); ok {
+		return items
+	}
+	raw, jerr := json.Marshal(e.Data)
+	if jerr != nil {
+		return nil
+	}
+	var items []FieldError
+	if json.Unmarshal(raw, &items) != nil {
+		return nil
+	}
+	for _, item := range items {
+		if item.Field == "" {
+			return nil
+		}
+	}
+	return items
+}
@@ -0,0 +1,92 @@
+package think
+
+import (
+	"encoding/json"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	var errs MultiError
+	if errs.Err() != nil {
+		t.Fatal("an empty MultiError is no error")
+	}
+	var items MultiError
+	items.AddIndex(0, nil).AddIndex(1, ErrRepeat("sku 9"))
+	errs.Add("name", ErrParam("required")).Add("items", &items).Add("stock", ErrUnavailable("inventory"))
+
+	err := errs.Err()
+	if errs.Len() != 3 || errs.Get("items.1") == nil || !IsErrRepeat(errs.Get("items.1")) {
+		t.Fatalf("got %v", err)
+	}
+	if GetCode(err) != CodeUnavailable || !IsServerFault(err) {
+		t.Fatalf("code = %d, want the most severe one", GetCode(err))
+	}
+	if !errors.Is(err, errs.Get("name")) {
+		t.Fatal("errors.Is should see the items")
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeParamError {
+		t.Fatal("errors.As should find the first item")
+	}
+
+	got := FromError(err)
+	if got.Message != CodeUnavailable.ToString() || !reflect.DeepEqual(got.Data, errs.Errors()) {
+		t.Fatalf("got %+v", got.Response)
+	}
+	var m *MultiError
+	if !errors.As(got, &m) || m != &errs {
+		t.Fatal("the collapsed error should wrap the MultiError")
+	}
+}
+
+func TestMultiErrorWire(t *testing.T) {
+	var errs MultiError
+	errs.Add("name", ErrParam("")).Add("age", New(CodeParamError, "", "too young", ""))
+
+	gs, _ := status.FromError(errs.Err())
+	var br *errdetails.BadRequest
+	for _, d := range gs.Details() {
+		if d, ok := d.(*errdetails.BadRequest); ok {
+			br = d
+		}
+	}
+	if br == nil || len(br.FieldViolations) != 2 || br.FieldViolations[1].Description != "too young" {
+		t.Fatalf("details = %v", gs.Details())
+	}
+
+	want := errs.Errors()
+	want[0].Data, want[1].Data = nil, nil
+	got := FieldErrors(wire(t, errs.Err()))
+	for i := range got {
+		got[i].Data = nil
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("grpc: got %+v, want %+v", got, want)
+	}
+
+	raw, _ := json.Marshal(FromError(errs.Err()).Response)
+	var res Response
+	_ = json.Unmarshal(raw, &res)
+	decoded := New(res.Code, "", res.Message, "")
+	decoded.Data = res.Data
+	if items := FieldErrors(decoded); len(items) != 2 || items[1].Message != "too young" {
+		t.Fatalf("json: got %+v", items)
+	}
+	if FieldErrors(ErrParam("x")) != nil || FieldErrors(io.EOF) != nil {
+		t.Fatal("plain errors have no field errors")
+	}
+}
+
+func TestMultiErrorLocalize(t *testing.T) {
+	var errs MultiError
+	errs.Add("name", ErrParam("")).Add("age", New(CodeParamError, "", "too young", ""))
+	items := Localize(errs.Err(), "en").Data.([]FieldError)
+	if items[0].Message != "invalid parameter" || items[1].Message != "too young" {
+		t.Fatalf("got %+v", items)
+	}
+}
@@ -2,18 +2,36 @@ package think
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"runtime"
 )
 
-func Recover(ctx context.Context) {
-	if rErr := recover(); rErr != nil {
-		buf := make([]byte, 64<<10)
-		n := runtime.Stack(buf, false)
-		buf = buf[:n]
-		log.Printf("Printf err: %v \n", rErr)
-		log.Println(string(buf))
+// PanicIDKey is the metadata key of the id tying a panic response to its log.
+const PanicIDKey = "panic_id"
 
-		return
+// Recover logs a recovered panic with its stack under a new panic id and
+// returns it as a CodePanicError carrying that id. It must run in the
+// deferred call that recovered, so the stack still shows the panic.
+func Recover(ctx context.Context, rec interface{}) error {
+	id := newPanicID()
+	buf := make([]byte, 64<<10)
+	n := runtime.Stack(buf, false)
+	log.Printf("[Recover] panic_id: %s err: %v\n%s", id, rec, buf[:n])
+
+	err := New(CodePanicError, "", CodePanicError.ToString(), "panic_id: "+id)
+	if cause, ok := rec.(error); ok {
+		err = err.WithCause(cause)
+	} else {
+		err = err.WithCause(fmt.Errorf("%v", rec))
 	}
+	return err.WithMetadata(map[string]string{PanicIDKey: id})
+}
+
+func newPanicID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
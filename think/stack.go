@@ -0,0 +1,46 @@
+package think
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+const maxStackDepth = 32
+
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return pcs[:n]
+}
+
+// Format prints the stack captured by New after the error with %+v.
+func (e *Error) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		_, _ = io.WriteString(s, e.Error())
+		if s.Flag('+') {
+			frames := runtime.CallersFrames(e.stack)
+			for {
+				f, more := frames.Next()
+				if f.Function != "" {
+					_, _ = fmt.Fprintf(s, "\n%s\n\t%s:%d", f.Function, f.File, f.Line)
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	default:
+		_, _ = fmt.Fprintf(s, "%%!%c(think.Error=%s)", verb, e.Error())
+	}
+}
+
+// StackTrace returns the program counters captured by New.
+func (e *Error) StackTrace() []uintptr {
+	return e.stack
+}
@@ -1,16 +1,193 @@
+// Code generated by gen_codes.go; DO NOT EDIT.
+
 package think
 
-func ErrUndefined(reason string) error {
-	return New(CodeUndefined, "", CodeUndefined.ToString(), reason)
+// ErrBiz returns a CodeBizError error (业务错误 用户空间错误) with reason as its data.
+func ErrBiz(reason string) error {
+	return newError(CodeBizError, "", CodeBizError.ToString(), reason)
 }
 
-func IsErrUndefined(err error) bool {
-	return GetCode(err) == CodeUndefined
+// IsErrBiz reports whether the code of err is CodeBizError.
+func IsErrBiz(err error) bool {
+	return GetCode(err) == CodeBizError
+}
+
+// ErrAlter returns a CodeAlterError error (简单错误) with reason as its data.
+func ErrAlter(reason string) error {
+	return newError(CodeAlterError, "", CodeAlterError.ToString(), reason)
+}
+
+// IsErrAlter reports whether the code of err is CodeAlterError.
+func IsErrAlter(err error) bool {
+	return GetCode(err) == CodeAlterError
+}
+
+// ErrParam returns a CodeParamError error (参数错误) with reason as its data.
+func ErrParam(reason string) error {
+	return newError(CodeParamError, "", CodeParamError.ToString(), reason)
+}
+
+// IsErrParam reports whether the code of err is CodeParamError.
+func IsErrParam(err error) bool {
+	return GetCode(err) == CodeParamError
+}
+
+// ErrNotFound returns a CodeNotFound error (记录错误) with reason as its data.
+func ErrNotFound(reason string) error {
+	return newError(CodeNotFound, "", CodeNotFound.ToString(), reason)
+}
+
+// IsErrNotFound reports whether the code of err is CodeNotFound.
+func IsErrNotFound(err error) bool {
+	return GetCode(err) == CodeNotFound
+}
+
+// ErrRepeat returns a CodeRepeat error (重复操作, 表示已存在) with reason as its data.
+func ErrRepeat(reason string) error {
+	return newError(CodeRepeat, "", CodeRepeat.ToString(), reason)
+}
+
+// IsErrRepeat reports whether the code of err is CodeRepeat.
+func IsErrRepeat(err error) bool {
+	return GetCode(err) == CodeRepeat
+}
+
+// ErrMethodNotAllowed returns a CodeMethodNotAllowed error (请求方法不允许) with reason as its data.
+func ErrMethodNotAllowed(reason string) error {
+	return newError(CodeMethodNotAllowed, "", CodeMethodNotAllowed.ToString(), reason)
+}
+
+// IsErrMethodNotAllowed reports whether the code of err is CodeMethodNotAllowed.
+func IsErrMethodNotAllowed(err error) bool {
+	return GetCode(err) == CodeMethodNotAllowed
+}
+
+// ErrUnDone returns a CodeUnDone error (记录错误) with reason as its data.
+func ErrUnDone(reason string) error {
+	return newError(CodeUnDone, "", CodeUnDone.ToString(), reason)
+}
+
+// IsErrUnDone reports whether the code of err is CodeUnDone.
+func IsErrUnDone(err error) bool {
+	return GetCode(err) == CodeUnDone
+}
+
+// ErrForbidden returns a CodeForbidden error (禁止访问) with reason as its data.
+func ErrForbidden(reason string) error {
+	return newError(CodeForbidden, "", CodeForbidden.ToString(), reason)
+}
+
+// IsErrForbidden reports whether the code of err is CodeForbidden.
+func IsErrForbidden(err error) bool {
+	return GetCode(err) == CodeForbidden
+}
+
+// ErrSign returns a CodeSignError error (签名错误) with reason as its data.
+func ErrSign(reason string) error {
+	return newError(CodeSignError, "", CodeSignError.ToString(), reason)
+}
+
+// IsErrSign reports whether the code of err is CodeSignError.
+func IsErrSign(err error) bool {
+	return GetCode(err) == CodeSignError
+}
+
+// ErrUnauthorized returns a CodeUnauthorized error (未认证) with reason as its data.
+func ErrUnauthorized(reason string) error {
+	return newError(CodeUnauthorized, "", CodeUnauthorized.ToString(), reason)
 }
+
+// IsErrUnauthorized reports whether the code of err is CodeUnauthorized.
+func IsErrUnauthorized(err error) bool {
+	return GetCode(err) == CodeUnauthorized
+}
+
+// ErrTooManyRequests returns a CodeTooManyRequests error (请求过于频繁) with reason as its data.
+func ErrTooManyRequests(reason string) error {
+	return newError(CodeTooManyRequests, "", CodeTooManyRequests.ToString(), reason)
+}
+
+// IsErrTooManyRequests reports whether the code of err is CodeTooManyRequests.
+func IsErrTooManyRequests(err error) bool {
+	return GetCode(err) == CodeTooManyRequests
+}
+
+// ErrSystemSpace returns a CodeSystemSpaceError error (系统空间错误  不外抛) with reason as its data.
 func ErrSystemSpace(reason string) error {
-	return New(CodeSystemSpaceError, "", CodeSystemSpaceError.ToString(), reason)
+	return newError(CodeSystemSpaceError, "", CodeSystemSpaceError.ToString(), reason)
 }
 
+// IsErrSystemSpace reports whether the code of err is CodeSystemSpaceError.
 func IsErrSystemSpace(err error) bool {
 	return GetCode(err) == CodeSystemSpaceError
 }
+
+// ErrPanic returns a CodePanicError error (系统崩溃错误) with reason as its data.
+func ErrPanic(reason string) error {
+	return newError(CodePanicError, "", CodePanicError.ToString(), reason)
+}
+
+// IsErrPanic reports whether the code of err is CodePanicError.
+func IsErrPanic(err error) bool {
+	return GetCode(err) == CodePanicError
+}
+
+// ErrIgnore returns a CodeIgnore error (忽略) with reason as its data.
+func ErrIgnore(reason string) error {
+	return newError(CodeIgnore, "", CodeIgnore.ToString(), reason)
+}
+
+// IsErrIgnore reports whether the code of err is CodeIgnore.
+func IsErrIgnore(err error) bool {
+	return GetCode(err) == CodeIgnore
+}
+
+// ErrUndefined returns a CodeUndefined error (未定义) with reason as its data.
+func ErrUndefined(reason string) error {
+	return newError(CodeUndefined, "", CodeUndefined.ToString(), reason)
+}
+
+// IsErrUndefined reports whether the code of err is CodeUndefined.
+func IsErrUndefined(err error) bool {
+	return GetCode(err) == CodeUndefined
+}
+
+// ErrTimeOut returns a CodeTimeOut error (超时) with reason as its data.
+func ErrTimeOut(reason string) error {
+	return newError(CodeTimeOut, "", CodeTimeOut.ToString(), reason)
+}
+
+// IsErrTimeOut reports whether the code of err is CodeTimeOut.
+func IsErrTimeOut(err error) bool {
+	return GetCode(err) == CodeTimeOut
+}
+
+// ErrException returns a CodeException error (异常) with reason as its data.
+func ErrException(reason string) error {
+	return newError(CodeException, "", CodeException.ToString(), reason)
+}
+
+// IsErrException reports whether the code of err is CodeException.
+func IsErrException(err error) bool {
+	return GetCode(err) == CodeException
+}
+
+// ErrType returns a CodeTypeError error (类型错误) with reason as its data.
+func ErrType(reason string) error {
+	return newError(CodeTypeError, "", CodeTypeError.ToString(), reason)
+}
+
+// IsErrType reports whether the code of err is CodeTypeError.
+func IsErrType(err error) bool {
+	return GetCode(err) == CodeTypeError
+}
+
+// ErrUnavailable returns a CodeUnavailable error (不可达) with reason as its data.
+func ErrUnavailable(reason string) error {
+	return newError(CodeUnavailable, "", CodeUnavailable.ToString(), reason)
+}
+
+// IsErrUnavailable reports whether the code of err is CodeUnavailable.
+func IsErrUnavailable(err error) bool {
+	return GetCode(err) == CodeUnavailable
+}
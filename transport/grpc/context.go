@@ -2,6 +2,8 @@ package grpc
 
 import (
 	"context"
+	"github.com/zander-84/gull/transport"
+	"google.golang.org/grpc/metadata"
 	"time"
 )
 
@@ -10,15 +12,17 @@ var _ Context = (*wrapper)(nil)
 // Context is an HTTP Context.
 type Context interface {
 	context.Context
+	RemoteIP() string
 }
 
 func NewGrpcContext(ctx context.Context) Context {
-	w := &wrapper{ctx: ctx}
+	w := &wrapper{ctx: ctx, reply: metadata.MD{}}
 	return w
 }
 
 type wrapper struct {
-	ctx context.Context
+	ctx   context.Context
+	reply metadata.MD
 }
 
 func (c *wrapper) Deadline() (time.Time, bool) {
@@ -34,5 +38,8 @@ func (c *wrapper) Err() error {
 }
 
 func (c *wrapper) Value(key interface{}) interface{} {
+	if v, ok := transport.ServerValue(c, key); ok {
+		return v
+	}
 	return c.ctx.Value(key)
 }
@@ -0,0 +1,26 @@
+package grpc
+
+import (
+	"context"
+	"github.com/zander-84/gull/think"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// LocalizeInterceptor sends errors with the catalogue message in the
+// accept-language metadata of the call, see think.Localize.
+func LocalizeInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		if langs := md.Get("accept-language"); len(langs) > 0 {
+			if locale := think.MatchLocale(langs[0]); locale != "" {
+				return resp, think.Localize(err, locale)
+			}
+		}
+		return resp, err
+	}
+}
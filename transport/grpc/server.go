@@ -4,7 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"github.com/zander-84/gull/internal/endpoint"
-	"github.com/zander-84/gull/internal/host"
+	"github.com/zander-84/gull/internal/listen"
 	"github.com/zander-84/gull/transport"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -19,6 +19,7 @@ import (
 var (
 	_ transport.Server     = (*Server)(nil)
 	_ transport.Endpointer = (*Server)(nil)
+	_ transport.Readier    = (*Server)(nil)
 )
 
 // Server is a gRPC server wrapper.
@@ -32,15 +33,43 @@ type Server struct {
 	lis     net.Listener
 	tlsConf *tls.Config
 	health  *health.Server
+	ready   chan struct{}
+
+	unaryInts  []grpc.UnaryServerInterceptor
+	streamInts []grpc.StreamServerInterceptor
 }
 type ServerOption func(o *Server)
 
-// NewServer creates a gRPC server by options.
+// UnaryInterceptor adds unary server interceptors, run in the given order.
+func UnaryInterceptor(in ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(s *Server) {
+		s.unaryInts = append(s.unaryInts, in...)
+	}
+}
+
+// StreamInterceptor adds stream server interceptors, run in the given order.
+func StreamInterceptor(in ...grpc.StreamServerInterceptor) ServerOption {
+	return func(s *Server) {
+		s.streamInts = append(s.streamInts, in...)
+	}
+}
+
+// Listener with server lis.
+func Listener(lis net.Listener) ServerOption {
+	return func(s *Server) {
+		s.lis = lis
+	}
+}
+
+// NewServer creates a gRPC server by options. Besides host:port, addr may
+// be unix:///path/to.sock or systemd:// for a socket passed by systemd, see
+// Endpoint for the url of those.
 func NewServer(addr string, opts ...ServerOption) *Server {
 
 	srv := &Server{
 		network: "tcp",
 		addr:    addr,
+		ready:   make(chan struct{}),
 	}
 	for _, o := range opts {
 		o(srv)
@@ -49,6 +78,14 @@ func NewServer(addr string, opts ...ServerOption) *Server {
 	grpcOpts := []grpc.ServerOption{}
 	if srv.tlsConf != nil {
 		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(srv.tlsConf)))
+		srv.unaryInts = append([]grpc.UnaryServerInterceptor{peerUnaryInterceptor}, srv.unaryInts...)
+		srv.streamInts = append([]grpc.StreamServerInterceptor{peerStreamInterceptor}, srv.streamInts...)
+	}
+	if len(srv.unaryInts) > 0 {
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(srv.unaryInts...))
+	}
+	if len(srv.streamInts) > 0 {
+		grpcOpts = append(grpcOpts, grpc.ChainStreamInterceptor(srv.streamInts...))
 	}
 	srv.Server = grpc.NewServer(grpcOpts...)
 
@@ -59,7 +96,7 @@ func NewServer(addr string, opts ...ServerOption) *Server {
 }
 func (s *Server) listenAndEndpoint() error {
 	if s.lis == nil {
-		lis, err := net.Listen(s.network, s.addr)
+		lis, err := listen.Listen(s.network, s.addr)
 		if err != nil {
 			s.err = err
 			return err
@@ -67,12 +104,12 @@ func (s *Server) listenAndEndpoint() error {
 		s.lis = lis
 	}
 	if s.endpoint == nil {
-		addr, err := host.Extract(s.addr, s.lis)
+		u, err := listen.Endpoint(endpoint.Scheme("grpc", s.tlsConf != nil), s.addr, s.lis)
 		if err != nil {
 			s.err = err
 			return err
 		}
-		s.endpoint = endpoint.NewEndpoint(endpoint.Scheme("grpc", s.tlsConf != nil), addr)
+		s.endpoint = u
 	}
 	return s.err
 }
@@ -84,7 +121,12 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 	log.Printf("[GRPC] server listening on: %s \n", s.lis.Addr().String())
 	//s.health.Resume()
-	return s.Server.Serve(s.lis)
+	return s.Server.Serve(listen.Accepting(s.lis, func() { close(s.ready) }))
+}
+
+// Ready is closed once the server accepts connections.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
 }
 
 // Stop the gRPC server.
@@ -108,7 +150,8 @@ func (s *Server) Stop(ctx context.Context) error {
 // Endpoint return a real address to registry endpoint.
 // examples:
 //
-//	grpc://127.0.0.1:9000?isSecure=false
+//	grpc://127.0.0.1:9000
+//	grpc+unix:///run/app.sock
 func (s *Server) Endpoint() (*url.URL, error) {
 	if err := s.listenAndEndpoint(); err != nil {
 		return nil, s.err
@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/zander-84/gull/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// TLSConfig with server tls config, like transport.TLSProvider.Config for
+// rotated certificates and mTLS.
+func TLSConfig(c *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConf = c
+	}
+}
+
+// peerIdentity returns ctx with the verified client certificate of the call,
+// see transport.PeerIdentityFromContext.
+func peerIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if id, ok := transport.PeerIdentityFromState(&info.State); ok {
+		return transport.NewPeerIdentityContext(ctx, id)
+	}
+	return ctx
+}
+
+func peerUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(peerIdentity(ctx), req)
+}
+
+type peerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *peerStream) Context() context.Context { return s.ctx }
+
+func peerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &peerStream{ServerStream: ss, ctx: peerIdentity(ss.Context())})
+}
@@ -0,0 +1,86 @@
+package grpc
+
+import (
+	"github.com/zander-84/gull/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+)
+
+var _ transport.Transporter = (*wrapper)(nil)
+
+type headerCarrier metadata.MD
+
+// Get returns the value associated with the passed key.
+func (mc headerCarrier) Get(key string) string {
+	vals := metadata.MD(mc).Get(key)
+	if len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Set stores the key-value pair.
+func (mc headerCarrier) Set(key string, value string) {
+	metadata.MD(mc).Set(key, value)
+}
+
+// Keys lists the keys stored in this carrier.
+func (mc headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for k := range metadata.MD(mc) {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// replyCarrier keeps the reply metadata and sends every change as a grpc header.
+type replyCarrier struct {
+	headerCarrier
+	w *wrapper
+}
+
+func (rc replyCarrier) Set(key string, value string) {
+	rc.headerCarrier.Set(key, value)
+	_ = grpc.SetHeader(rc.w.ctx, metadata.Pairs(key, value))
+}
+
+func (c *wrapper) Kind() transport.Kind {
+	return transport.KindGRPC
+}
+
+// Endpoint is unknown to a unary call context.
+func (c *wrapper) Endpoint() string {
+	return ""
+}
+
+func (c *wrapper) Operation() string {
+	method, _ := grpc.Method(c.ctx)
+	return method
+}
+
+func (c *wrapper) RequestHeader() transport.Header {
+	md, ok := metadata.FromIncomingContext(c.ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+	return headerCarrier(md)
+}
+
+func (c *wrapper) ReplyHeader() transport.Header {
+	return replyCarrier{headerCarrier: headerCarrier(c.reply), w: c}
+}
+
+// RemoteIP returns the ip of the connected peer.
+func (c *wrapper) RemoteIP() string {
+	p, ok := peer.FromContext(c.ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
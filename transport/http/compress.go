@@ -0,0 +1,390 @@
+package http
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"github.com/zander-84/gull/think"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressWriter is the writer of a content coding, like *gzip.Writer.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Coding encodes responses and decodes request bodies of a Content-Encoding.
+type Coding struct {
+	Name    string
+	Encoder func(w io.Writer, level int) (CompressWriter, error)
+	Decoder func(r io.Reader) (io.ReadCloser, error)
+}
+
+var codings = struct {
+	lock   sync.RWMutex
+	order  []string
+	byName map[string]Coding
+}{byName: make(map[string]Coding)}
+
+// RegisterCoding adds a content coding, like brotli from a third party
+// package. Later registrations are preferred when a client accepts several
+// with the same weight.
+func RegisterCoding(c Coding) {
+	codings.lock.Lock()
+	defer codings.lock.Unlock()
+	if _, ok := codings.byName[c.Name]; !ok {
+		codings.order = append([]string{c.Name}, codings.order...)
+	}
+	codings.byName[c.Name] = c
+}
+
+func init() {
+	RegisterCoding(Coding{
+		Name: "deflate",
+		Encoder: func(w io.Writer, level int) (CompressWriter, error) {
+			return flate.NewWriter(w, level)
+		},
+		Decoder: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+	})
+	RegisterCoding(Coding{
+		Name: "gzip",
+		Encoder: func(w io.Writer, level int) (CompressWriter, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		Decoder: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	})
+}
+
+// CompressOption is an option of Compress.
+type CompressOption func(*compressOptions)
+
+type compressOptions struct {
+	level   int
+	minSize int
+	types   []string
+	maxBody int64
+}
+
+// CompressLevel sets the compression level, gzip.DefaultCompression by default.
+func CompressLevel(level int) CompressOption {
+	return func(o *compressOptions) {
+		o.level = level
+	}
+}
+
+// CompressMinSize leaves responses smaller than n bytes uncompressed, 1024
+// by default.
+func CompressMinSize(n int) CompressOption {
+	return func(o *compressOptions) {
+		o.minSize = n
+	}
+}
+
+// CompressTypes sets the content types to compress, a trailing "/*" matches
+// a whole type. Text, json, xml and javascript by default.
+func CompressTypes(types ...string) CompressOption {
+	return func(o *compressOptions) {
+		o.types = types
+	}
+}
+
+// DecompressMaxBody bounds decompressed request bodies, 32MB by default,
+// 0 means no bound.
+func DecompressMaxBody(n int64) CompressOption {
+	return func(o *compressOptions) {
+		o.maxBody = n
+	}
+}
+
+// ServerCompression compresses responses and decompresses request bodies,
+// see Compress.
+func ServerCompression(opts ...CompressOption) ServerOption {
+	return func(s *Server) {
+		s.compress = opts
+		if s.compress == nil {
+			s.compress = []CompressOption{}
+		}
+	}
+}
+
+// Compress wraps h to compress responses in the coding the client prefers
+// in Accept-Encoding, so JSON, Blob and Stream of Context all benefit, and
+// to decode request bodies sent with a Content-Encoding. Unknown request
+// codings get a think envelope with 415.
+func Compress(h http.Handler, opts ...CompressOption) http.Handler {
+	o := compressOptions{
+		level:   gzip.DefaultCompression,
+		minSize: 1024,
+		types: []string{"text/*", "application/json", "application/xml", "application/javascript",
+			"application/x-javascript", "image/svg+xml"},
+		maxBody: 32 << 20,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := decompress(req, o.maxBody); err != nil {
+			encodeStatus(w, req, http.StatusUnsupportedMediaType, think.ErrParam(err.Error()))
+			return
+		}
+		coding, ok := negotiate(req.Header.Get("Accept-Encoding"))
+		if !ok || req.Method == http.MethodHead {
+			h.ServeHTTP(w, req)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, opts: &o, coding: coding}
+		defer cw.close()
+		h.ServeHTTP(cw, req)
+	})
+}
+
+func decompress(req *http.Request, maxBody int64) error {
+	enc := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if enc == "" || enc == "identity" || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	codings.lock.RLock()
+	c, ok := codings.byName[enc]
+	codings.lock.RUnlock()
+	if !ok || c.Decoder == nil {
+		return errors.New("unsupported content encoding " + enc)
+	}
+	body, err := c.Decoder(req.Body)
+	if err != nil {
+		return err
+	}
+	req.Body = &decodedBody{ReadCloser: body, raw: req.Body, left: maxBody, bounded: maxBody > 0}
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	return nil
+}
+
+// ErrBodyTooLarge is read from request bodies decompressed beyond DecompressMaxBody.
+var ErrBodyTooLarge = errors.New("http: decompressed request body too large")
+
+type decodedBody struct {
+	io.ReadCloser
+	raw     io.Closer
+	left    int64
+	bounded bool
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.bounded {
+		if b.left <= 0 {
+			// a body of exactly the bound still ends well
+			var one [1]byte
+			if n, err := b.ReadCloser.Read(one[:]); n == 0 && err == io.EOF {
+				return 0, io.EOF
+			}
+			return 0, ErrBodyTooLarge
+		}
+		if int64(len(p)) > b.left {
+			p = p[:b.left]
+		}
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	_ = b.ReadCloser.Close()
+	return b.raw.Close()
+}
+
+// negotiate picks the registered coding with the highest weight in an
+// Accept-Encoding header.
+func negotiate(accept string) (Coding, bool) {
+	if accept == "" {
+		return Coding{}, false
+	}
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v := strings.TrimSpace(f); strings.HasPrefix(v, "q=") {
+				if parsed, err := strconv.ParseFloat(v[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		weights[name] = q
+	}
+
+	codings.lock.RLock()
+	defer codings.lock.RUnlock()
+	var best Coding
+	bestQ := 0.0
+	for _, name := range codings.order {
+		q, ok := weights[name]
+		if !ok {
+			q, ok = weights["*"]
+		}
+		if ok && q > bestQ && codings.byName[name].Encoder != nil {
+			best, bestQ = codings.byName[name], q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compressWriter holds the first bytes of a response until it knows
+// whether to compress: the content type is allowed and the body reaches
+// the minimum size or is flushed.
+type compressWriter struct {
+	http.ResponseWriter
+	opts   *compressOptions
+	coding Coding
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     CompressWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	// informational statuses but 101 go out at once, before the final one
+	if w.status == 0 && code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.opts.minSize {
+			return len(p), nil
+		}
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// start decides on the buffered bytes and writes them.
+func (w *compressWriter) start() error {
+	w.decide(w.compressible())
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(w.buf)
+		h.Set("Content-Type", ct)
+	}
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	ct = strings.ToLower(strings.TrimSpace(ct))
+	for _, t := range w.opts.types {
+		if t == ct || (strings.HasSuffix(t, "/*") && strings.HasPrefix(ct, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) decide(compress bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if compress {
+		if enc, err := w.coding.Encoder(w.ResponseWriter, w.opts.level); err == nil {
+			w.enc = enc
+			h.Set("Content-Encoding", w.coding.Name)
+			h.Del("Content-Length")
+			h.Add("Vary", "Accept-Encoding")
+		}
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Flush compresses what is held, whatever its size, as streams need it now.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.start()
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrStreamUnsupported
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+		if len(w.buf) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf)
+		}
+		return
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+	}
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gunzip(t *testing.T, r io.Reader) string {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	big := strings.Repeat("gull ", 500)
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := NewHttpContext(w, req)
+		switch req.URL.Path {
+		case "/json":
+			_ = c.JSON(http.StatusOK, map[string]string{"text": big})
+		case "/small":
+			_ = c.String(http.StatusOK, "tiny")
+		case "/png":
+			_ = c.Blob(http.StatusOK, "image/png", []byte(big))
+		case "/stream":
+			_ = c.Stream(http.StatusCreated, "text/plain", strings.NewReader(big))
+		}
+	}), CompressMinSize(100))
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/json", "deflate;q=0.5, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers %v", rec.Header())
+	}
+	if got := gunzip(t, rec.Body); !strings.Contains(got, big) {
+		t.Fatalf("got %.40q", got)
+	}
+	rec = get("/stream", "gzip")
+	if rec.Code != http.StatusCreated || gunzip(t, rec.Body) != big {
+		t.Fatalf("stream: %d", rec.Code)
+	}
+	if rec = get("/json", "deflate"); rec.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("deflate: %v", rec.Header())
+	}
+	for path, accept := range map[string]string{"/small": "gzip", "/png": "gzip", "/json": "gzip;q=0, br"} {
+		rec = get(path, accept)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Code != http.StatusOK {
+			t.Fatalf("%s with %s should not be compressed: %v", path, accept, rec.Header())
+		}
+	}
+}
+
+// headerRecorder notes every status written, httptest.ResponseRecorder only
+// keeps the first.
+type headerRecorder struct {
+	*httptest.ResponseRecorder
+	codes []int
+}
+
+func (r *headerRecorder) WriteHeader(code int) {
+	r.codes = append(r.codes, code)
+	if code >= 200 {
+		r.ResponseRecorder.WriteHeader(code)
+	}
+}
+
+func TestCompressInformational(t *testing.T) {
+	big := strings.Repeat("gull ", 500)
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		_ = NewHttpContext(w, req).JSON(http.StatusNotFound, map[string]string{"text": big})
+	}), CompressMinSize(100))
+
+	rec := &headerRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rec, req)
+	if len(rec.codes) != 2 || rec.codes[0] != http.StatusEarlyHints || rec.codes[1] != http.StatusNotFound {
+		t.Fatalf("statuses %v, want 103 then 404", rec.codes)
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" || !strings.Contains(gunzip(t, rec.Body), big) {
+		t.Fatalf("headers %v", rec.Header())
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		_, _ = w.Write(b)
+	}), DecompressMaxBody(10))
+
+	post := func(body, encoding string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(body))
+		_ = zw.Close()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", &buf)
+		req.Header.Set("Content-Encoding", encoding)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post("0123456789", "gzip"); rec.Body.String() != "0123456789" {
+		t.Fatalf("got %q", rec.Body)
+	}
+	if rec := post("0123456789+", "gzip"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("a body beyond the bound should fail, got %d", rec.Code)
+	}
+	if rec := post("x", "zstd"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unknown codings should be refused, got %d", rec.Code)
+	}
+}
+
+func TestCompressSSE(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", ServerRouter(), ServerCompression())
+	srv.Router().HandleFunc(http.MethodGet, "/events", func(w http.ResponseWriter, req *http.Request) {
+		_ = NewHttpContext(w, req).SSE(func(s *EventStream) error {
+			return s.Send(Event{Data: "hello"})
+		}, SSEHeartbeat(0))
+	})
+	host := startServer(t, srv)
+	defer srv.Stop(context.Background())
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" || gunzip(t, res.Body) != "data: hello\n\n" {
+		t.Fatal("flushed streams should be compressed as they go")
+	}
+}
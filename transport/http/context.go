@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"encoding/xml"
+	"github.com/zander-84/gull/transport"
+	"golang.org/x/net/websocket"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,12 +18,12 @@ var _ Context = (*wrapper)(nil)
 // Context is an HTTP Context.
 type Context interface {
 	context.Context
-	//Vars() url.Values
+	Vars() url.Values
 	Query() url.Values
 	Form() url.Values
 	Header() http.Header
 	Request() *http.Request
-	Response() http.ResponseWriter
+	Response() ResponseWriter
 	//Bind(interface{}) error
 	//BindVars(interface{}) error
 	//BindQuery(interface{}) error
@@ -32,7 +35,10 @@ type Context interface {
 	String(int, string) error
 	Blob(int, string, []byte) error
 	Stream(int, string, io.Reader) error
+	SSE(func(*EventStream) error, ...SSEOption) error
+	WebSocket(func(*websocket.Conn) error) error
 	Reset(http.ResponseWriter, *http.Request)
+	RemoteIP() string
 }
 
 func NewHttpContext(res http.ResponseWriter, req *http.Request) Context {
@@ -41,25 +47,9 @@ func NewHttpContext(res http.ResponseWriter, req *http.Request) Context {
 	return w
 }
 
-type responseWriter struct {
-	code int
-	w    http.ResponseWriter
-}
-
-func (w *responseWriter) rest(res http.ResponseWriter) {
-	w.w = res
-	w.code = http.StatusOK
-}
-func (w *responseWriter) Header() http.Header        { return w.w.Header() }
-func (w *responseWriter) WriteHeader(statusCode int) { w.code = statusCode }
-func (w *responseWriter) Write(data []byte) (int, error) {
-	w.w.WriteHeader(w.code)
-	return w.w.Write(data)
-}
-
 type wrapper struct {
 	req *http.Request
-	res http.ResponseWriter
+	res ResponseWriter
 	w   responseWriter
 }
 
@@ -74,27 +64,45 @@ func (c *wrapper) Form() url.Values {
 	return c.req.Form
 }
 
+// Vars returns the path parameters matched by Router.
+func (c *wrapper) Vars() url.Values {
+	return Vars(c.req)
+}
+
 func (c *wrapper) Query() url.Values {
 	return c.req.URL.Query()
 }
 
-func (c *wrapper) Request() *http.Request        { return c.req }
-func (c *wrapper) Response() http.ResponseWriter { return c.res }
+func (c *wrapper) Request() *http.Request   { return c.req }
+func (c *wrapper) Response() ResponseWriter { return c.res }
+
+// Written reports whether the response was sent, for packages that can not
+// import this one to call Response().Written(), like endpoint.
+func (c *wrapper) Written() bool { return c.res.Written() }
 
 func (c *wrapper) JSON(code int, v interface{}) error {
 	c.res.Header().Set("Content-Type", "application/json")
+	if c.notModified(code) {
+		return nil
+	}
 	c.res.WriteHeader(code)
 	return json.NewEncoder(c.res).Encode(v)
 }
 
 func (c *wrapper) XML(code int, v interface{}) error {
 	c.res.Header().Set("Content-Type", "application/xml")
+	if c.notModified(code) {
+		return nil
+	}
 	c.res.WriteHeader(code)
 	return xml.NewEncoder(c.res).Encode(v)
 }
 
 func (c *wrapper) String(code int, text string) error {
 	c.res.Header().Set("Content-Type", "text/plain")
+	if c.notModified(code) {
+		return nil
+	}
 	c.res.WriteHeader(code)
 	_, err := c.res.Write([]byte(text))
 	if err != nil {
@@ -105,6 +113,9 @@ func (c *wrapper) String(code int, text string) error {
 
 func (c *wrapper) Blob(code int, contentType string, data []byte) error {
 	c.res.Header().Set("Content-Type", contentType)
+	if c.notModified(code) {
+		return nil
+	}
 	c.res.WriteHeader(code)
 	_, err := c.res.Write(data)
 	if err != nil {
@@ -115,14 +126,55 @@ func (c *wrapper) Blob(code int, contentType string, data []byte) error {
 
 func (c *wrapper) Stream(code int, contentType string, rd io.Reader) error {
 	c.res.Header().Set("Content-Type", contentType)
+	if c.notModified(code) {
+		return nil
+	}
 	c.res.WriteHeader(code)
 	_, err := io.Copy(c.res, rd)
 	return err
 }
 
+// notModified answers a GET or HEAD whose If-None-Match matches the ETag
+// already set on the response with 304 and no body.
+func (c *wrapper) notModified(code int) bool {
+	if code != http.StatusOK || (c.req.Method != http.MethodGet && c.req.Method != http.MethodHead) {
+		return false
+	}
+	etag := c.res.Header().Get("ETag")
+	if etag == "" || !etagMatch(c.req.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	h := c.res.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	c.res.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch uses the weak comparison of RFC 7232 on a If-None-Match list.
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset serves req on res, wrapped to note what is sent unless it already
+// is a ResponseWriter, as when a middleware resets the context.
 func (c *wrapper) Reset(res http.ResponseWriter, req *http.Request) {
-	c.w.rest(res)
-	c.res = res
+	if rw, ok := res.(ResponseWriter); ok {
+		c.res = rw
+	} else {
+		c.w.reset(res)
+		c.res = &c.w
+	}
 	c.req = req
 }
 
@@ -148,6 +200,9 @@ func (c *wrapper) Err() error {
 }
 
 func (c *wrapper) Value(key interface{}) interface{} {
+	if v, ok := transport.ServerValue(c, key); ok {
+		return v
+	}
 	if c.req == nil {
 		return nil
 	}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/zander-84/gull/think"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds the error bodies DecodeError reads.
+const maxErrorBody = 1 << 20
+
+// EncodeError renders err as think.Envelope for the Accept-Language of the
+// request, for endpoint.WrapError:
+//
+//	endpoint.OptionsErrorEncoder(endpoint.WrapError(map[endpoint.Protocol]func(ctx context.Context, err error){
+//		endpoint.Http: http.EncodeError,
+//	}))
+func EncodeError(ctx context.Context, err error) {
+	c, ok := ctx.(Context)
+	if !ok || err == nil {
+		return
+	}
+	_ = c.JSON(think.Envelope(err, c.Request().Header.Get("Accept-Language")))
+}
+
+// DecodeError returns nil for a 2xx response and otherwise the think.Error
+// in its body, as written by EncodeError. Bodies without an envelope give
+// the Code closest to the status, with the body text as Data. The body is
+// read but left for the caller to close.
+func DecodeError(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	var body []byte
+	if res.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	}
+
+	var r think.Response
+	if err := json.Unmarshal(body, &r); err == nil && r.Code >= think.MinCode {
+		e := think.New(r.Code, r.BizCode, r.Message, "")
+		e.Metadata = r.Metadata
+		e.Data = r.Data
+		return e
+	}
+	code := codeFromStatus(res.StatusCode)
+	reason := strings.TrimSpace(string(body))
+	if reason == "" {
+		reason = res.Status
+	}
+	return think.New(code, "", code.ToString(), reason)
+}
+
+func codeFromStatus(status int) think.Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType:
+		return think.CodeParamError
+	case http.StatusUnauthorized:
+		return think.CodeUnauthorized
+	case http.StatusForbidden:
+		return think.CodeForbidden
+	case http.StatusNotFound:
+		return think.CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return think.CodeMethodNotAllowed
+	case http.StatusConflict:
+		return think.CodeRepeat
+	case http.StatusTooManyRequests:
+		return think.CodeTooManyRequests
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return think.CodeUnavailable
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return think.CodeTimeOut
+	default:
+		return think.CodeSystemSpaceError
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"errors"
+	"github.com/zander-84/gull/think"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestErrorRoundTrip(t *testing.T) {
+	sent := think.New(think.CodeRepeat, "ORDER_EXISTS", "order exists", "id 7").WithMetadata(map[string]string{"id": "7"})
+
+	rec := httptest.NewRecorder()
+	EncodeError(NewHttpContext(rec, httptest.NewRequest(http.MethodPost, "/orders", nil)), sent)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("code = %d, content type = %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	got := think.FromError(DecodeError(rec.Result()))
+	if got.Code != sent.Code || got.BizCode != sent.BizCode || got.Message != sent.Message || got.Data != "id 7" {
+		t.Fatalf("got %+v, want %+v", got.Response, sent.Response)
+	}
+	if !reflect.DeepEqual(got.Metadata, sent.Metadata) {
+		t.Fatalf("metadata = %v", got.Metadata)
+	}
+}
+
+func TestEncodePlainError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	EncodeError(NewHttpContext(rec, httptest.NewRequest(http.MethodGet, "/", nil)), errors.New("db down"))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d", rec.Code)
+	}
+	if got := think.GetCode(DecodeError(rec.Result())); got != think.CodeUndefined {
+		t.Fatalf("code = %d, want CodeUndefined", got)
+	}
+}
+
+func TestDecodeForeignError(t *testing.T) {
+	ok := &http.Response{StatusCode: http.StatusNoContent}
+	if DecodeError(ok) != nil {
+		t.Fatal("2xx is not an error")
+	}
+
+	rec := httptest.NewRecorder()
+	http.Error(rec, "no such page", http.StatusNotFound)
+	got := think.FromError(DecodeError(rec.Result()))
+	if got.Code != think.CodeNotFound || got.Data != "no such page" {
+		t.Fatalf("got %+v", got.Response)
+	}
+
+	rec = httptest.NewRecorder()
+	rec.WriteHeader(http.StatusBadGateway)
+	if got = think.FromError(DecodeError(rec.Result())); got.Code != think.CodeUnavailable || !strings.Contains(got.Data.(string), "502") {
+		t.Fatalf("got %+v", got.Response)
+	}
+
+	for status, want := range map[int]think.Code{
+		http.StatusMethodNotAllowed:     think.CodeMethodNotAllowed,
+		http.StatusConflict:             think.CodeRepeat,
+		http.StatusUnsupportedMediaType: think.CodeParamError,
+		http.StatusTeapot:               think.CodeSystemSpaceError,
+	} {
+		rec = httptest.NewRecorder()
+		rec.WriteHeader(status)
+		if code := think.GetCode(DecodeError(rec.Result())); code != want {
+			t.Errorf("%d: code = %v, want %v", status, code, want)
+		}
+	}
+}
+
+func TestEncodeLocalized(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	EncodeError(NewHttpContext(rec, req), think.New(think.CodeForbidden, "", think.CodeForbidden.ToString(), ""))
+	if got := think.FromError(DecodeError(rec.Result())); got.Message != "forbidden" {
+		t.Fatalf("message = %s, want the english one", got.Message)
+	}
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"crypto/tls"
+	"github.com/zander-84/gull/internal/endpoint"
+	"github.com/zander-84/gull/transport"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+)
+
+// ServerH2C serves HTTP/2 without TLS next to HTTP/1.1 on plaintext
+// listeners, as service mesh sidecars speak it. The endpoint scheme becomes
+// "h2c" so registry consumers dial it with NewH2CTransport.
+func ServerH2C() ServerOption {
+	return func(s *Server) {
+		s.h2c = true
+	}
+}
+
+// ServerHTTP2 configures HTTP/2 connections, TLS or h2c, like
+// MaxConcurrentStreams and MaxReadFrameSize.
+func ServerHTTP2(conf *http2.Server) ServerOption {
+	return func(s *Server) {
+		s.http2 = conf
+	}
+}
+
+// ServerAltSvc sets the Alt-Svc header of every response, advertising an
+// HTTP/3 listener served next to the server, e.g. `h3=":443"; ma=86400`.
+func ServerAltSvc(altSvc string) ServerOption {
+	return func(s *Server) {
+		s.altSvc = altSvc
+	}
+}
+
+// configure applies the options to the embedded http.Server.
+func (s *Server) configure() {
+	s.Server.TLSConfig = s.tlsConf
+
+	if s.compress != nil {
+		s.Server.Handler = Compress(s.Server.Handler, s.compress...)
+	}
+	if s.altSvc != "" {
+		next, altSvc := s.Server.Handler, s.altSvc
+		s.Server.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Alt-Svc", altSvc)
+			next.ServeHTTP(w, req)
+		})
+	}
+	if len(s.trusted) > 0 {
+		s.Server.Handler = trustProxies(s.Server.Handler, s.trusted)
+	}
+	if s.tlsConf != nil {
+		s.Server.Handler = peerIdentity(s.Server.Handler)
+	}
+	conf := s.http2
+	if conf == nil {
+		conf = &http2.Server{}
+	}
+	if s.tlsConf != nil {
+		if err := http2.ConfigureServer(s.Server, conf); err != nil {
+			s.err = err
+		}
+	} else if s.h2c {
+		s.Server.Handler = h2c.NewHandler(s.Server.Handler, conf)
+	}
+}
+
+// peerIdentity puts the verified client certificate of mTLS requests in
+// their context, see transport.PeerIdentityFromContext.
+func peerIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if id, ok := transport.PeerIdentityFromState(req.TLS); ok {
+			req = req.WithContext(transport.NewPeerIdentityContext(req.Context(), id))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *Server) scheme() string {
+	if s.h2c && s.tlsConf == nil {
+		return "h2c"
+	}
+	return endpoint.Scheme("http", s.tlsConf != nil)
+}
+
+// NewH2CTransport returns a client transport speaking HTTP/2 without TLS to
+// servers with ServerH2C.
+func NewH2CTransport() http.RoundTripper {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+}
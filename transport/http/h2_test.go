@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"golang.org/x/net/http2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveH2(t *testing.T, srv *Server) string {
+	srv.Router().HandleFunc(http.MethodGet, "/proto", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.Proto))
+	})
+	u, err := srv.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Start(context.Background()) }()
+	t.Cleanup(func() { _ = srv.Stop(context.Background()) })
+	return u.String()
+}
+
+func TestH2C(t *testing.T) {
+	u := serveH2(t, NewServer("127.0.0.1:0", ServerRouter(), ServerH2C(), ServerHTTP2(&http2.Server{MaxConcurrentStreams: 10}), ServerAltSvc(`h3=":443"`)))
+	if u[:6] != "h2c://" {
+		t.Fatalf("endpoint = %s", u)
+	}
+
+	client := &http.Client{Transport: NewH2CTransport()}
+	res, err := client.Get("http" + u[3:] + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 2 || res.Header.Get("Alt-Svc") != `h3=":443"` {
+		t.Fatalf("proto = %s, alt-svc = %s", res.Proto, res.Header.Get("Alt-Svc"))
+	}
+
+	res, err = http.Get("http" + u[3:] + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 1 {
+		t.Fatal("HTTP/1.1 clients should still be served")
+	}
+}
+
+func TestH2TLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	certs := ts.TLS.Certificates
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	ts.Close()
+
+	u := serveH2(t, NewServer("127.0.0.1:0", ServerRouter(), ServerTLSConfig(&tls.Config{Certificates: certs})))
+	if u[:8] != "https://" {
+		t.Fatalf("endpoint = %s", u)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+	res, err := client.Get(u + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Fatalf("proto = %s", res.Proto)
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter is the writer of a Context. It notes what was sent so
+// middleware, like access logs and metrics, can read the final status.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	// Status returns the status sent, 0 while the headers are not.
+	Status() int
+	// Size returns the number of body bytes written.
+	Size() int64
+	// Written reports whether the headers were sent.
+	Written() bool
+	// Unwrap returns the writer of the server, for http.ResponseController.
+	Unwrap() http.ResponseWriter
+}
+
+var (
+	_ ResponseWriter = (*responseWriter)(nil)
+	_ http.Hijacker  = (*responseWriter)(nil)
+	_ http.Pusher    = (*responseWriter)(nil)
+)
+
+type responseWriter struct {
+	w      http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) reset(res http.ResponseWriter) {
+	w.w = res
+	w.status = 0
+	w.size = 0
+}
+
+func (w *responseWriter) Header() http.Header { return w.w.Header() }
+
+// WriteHeader sends the headers once, informational statuses but 101 may
+// come before the final one.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.w.WriteHeader(code)
+		return
+	}
+	w.status = code
+	w.w.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.w.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Status() int                 { return w.status }
+func (w *responseWriter) Size() int64                 { return w.size }
+func (w *responseWriter) Written() bool               { return w.status != 0 }
+func (w *responseWriter) Unwrap() http.ResponseWriter { return w.w }
+
+// Flush sends the headers, with 200 if none was set, and what is buffered.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over, the status is then 101.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.w.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// unwrap returns the writer of the server behind w, to tell whether it can
+// really flush or hijack.
+func unwrap(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		rw, ok := w.(ResponseWriter)
+		if !ok {
+			return w
+		}
+		w = rw.Unwrap()
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := NewHttpContext(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	res := c.Response()
+	if res.Written() || res.Status() != 0 || c.(interface{ Written() bool }).Written() {
+		t.Fatalf("written %v with %d before any write", res.Written(), res.Status())
+	}
+	if err := c.JSON(http.StatusCreated, map[string]string{"name": "gull"}); err != nil {
+		t.Fatal(err)
+	}
+	res.WriteHeader(http.StatusInternalServerError)
+	if !res.Written() || res.Status() != http.StatusCreated || rec.Code != http.StatusCreated {
+		t.Fatalf("status %d, sent %d", res.Status(), rec.Code)
+	}
+	if res.Size() != int64(rec.Body.Len()) || res.Size() == 0 {
+		t.Fatalf("size %d, sent %d", res.Size(), rec.Body.Len())
+	}
+	if res.Unwrap() != rec {
+		t.Fatal("Unwrap should return the writer of the server")
+	}
+
+	// a middleware resetting the context keeps what was noted
+	c.Reset(res, c.Request())
+	if c.Response() != res || c.Response().Status() != http.StatusCreated {
+		t.Fatal("resetting with the writer of the context should keep it")
+	}
+
+	if err := res.(http.Pusher).Push("/style.css", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("push error %v", err)
+	}
+	if _, _, err := res.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("hijack error %v", err)
+	}
+}
+
+func TestResponseWriterNotModified(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	c := NewHttpContext(rec, req)
+	c.Response().Header().Set("ETag", `"v1"`)
+	if err := c.JSON(http.StatusOK, "body"); err != nil {
+		t.Fatal(err)
+	}
+	if res := c.Response(); res.Status() != http.StatusNotModified || res.Size() != 0 {
+		t.Fatalf("status %d, size %d", res.Status(), res.Size())
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	status := make(chan int, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := NewHttpContext(w, req)
+		conn, _, err := c.Response().(http.Hijacker).Hijack()
+		if err != nil {
+			status <- 0
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+		_ = conn.Close()
+		status <- c.Response().Status()
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if s := <-status; s != http.StatusSwitchingProtocols {
+		t.Fatalf("status after hijack %d", s)
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := NewHttpContext(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	w := bufio.NewWriter(c.Response())
+	_, _ = w.WriteString("partial")
+	_ = w.Flush()
+	c.Response().Flush()
+	if !rec.Flushed || c.Response().Status() != http.StatusOK || c.Response().Size() != 7 {
+		t.Fatalf("flushed %v, status %d, size %d", rec.Flushed, c.Response().Status(), c.Response().Size())
+	}
+}
@@ -0,0 +1,266 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var _ http.Handler = (*Router)(nil)
+
+// RouterOption is a Router option.
+type RouterOption func(*routes)
+
+// RouterNotFound replaces the 404 answer of unknown paths.
+func RouterNotFound(h http.Handler) RouterOption {
+	return func(r *routes) {
+		r.notFound = h
+	}
+}
+
+// RouterMethodNotAllowed replaces the 405 answer of known paths without a
+// handler for the method, the Allow header is set before h runs.
+func RouterMethodNotAllowed(h http.Handler) RouterOption {
+	return func(r *routes) {
+		r.methodNotAllowed = h
+	}
+}
+
+// Router is the builtin router of Server. Paths hold static segments,
+// ":name" parameters matching one segment and a final "*name" matching the
+// rest, read back with Context.Vars or Vars. Static segments win over
+// parameters, which win over the catch-all.
+//
+// HEAD falls back to the GET handler and OPTIONS answers 204 with an Allow
+// header unless they are registered. Unknown paths and methods get a think
+// envelope with 404 or 405.
+//
+// Endpoint is an endpoint.ProxyEndpoint:
+//
+//	r := http.NewRouter()
+//	rmc.Proxy(r.Endpoint, endpoint.Http)
+//	srv := http.NewServer(":8000", http.ServerHandler(r))
+type Router struct {
+	prefix string
+	routes *routes
+}
+
+type routes struct {
+	root             *node
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+// NewRouter creates a Router.
+func NewRouter(opts ...RouterOption) *Router {
+	rs := &routes{root: &node{}}
+	for _, o := range opts {
+		o(rs)
+	}
+	return &Router{routes: rs}
+}
+
+// Group returns a Router registering its paths under prefix, sharing the
+// routes of r.
+func (r *Router) Group(prefix string) *Router {
+	return &Router{prefix: r.prefix + strings.TrimSuffix(prefix, "/"), routes: r.routes}
+}
+
+// Handle registers h for method and path, a path registered twice for the
+// same method panics.
+func (r *Router) Handle(method, path string, h http.Handler) {
+	path = r.prefix + path
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("http: router path %q must begin with /", path))
+	}
+	r.routes.root.add(method, path, splitPath(path), h)
+}
+
+// HandleFunc registers f for method and path.
+func (r *Router) HandleFunc(method, path string, f func(http.ResponseWriter, *http.Request)) {
+	r.Handle(method, path, http.HandlerFunc(f))
+}
+
+// Endpoint registers an endpoint, see endpoint.Rmc.Proxy.
+func (r *Router) Endpoint(protocol endpoint.Protocol, method endpoint.Method, path string, e endpoint.HandlerFunc) {
+	r.Handle(string(method), path, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v := endpoint.NewCtxVal()
+		v.SetProtocol(protocol)
+		req = req.WithContext(endpoint.WithContext(req.Context(), v))
+		_, _ = e(NewHttpContext(w, req), nil)
+	}))
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	n, vars := r.routes.root.match(splitPath(req.URL.Path), nil)
+	if n == nil {
+		r.routes.answerNotFound(w, req)
+		return
+	}
+
+	h := n.handlers[req.Method]
+	if h == nil && req.Method == http.MethodHead {
+		h = n.handlers[http.MethodGet]
+	}
+	if h == nil {
+		w.Header().Set("Allow", n.allow())
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		r.routes.answerMethodNotAllowed(w, req)
+		return
+	}
+	if len(vars) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), varsKey{}, vars))
+	}
+	h.ServeHTTP(w, req)
+}
+
+func (rs *routes) answerNotFound(w http.ResponseWriter, req *http.Request) {
+	if rs.notFound != nil {
+		rs.notFound.ServeHTTP(w, req)
+		return
+	}
+	encodeStatus(w, req, http.StatusNotFound, think.ErrNotFound(req.URL.Path))
+}
+
+func (rs *routes) answerMethodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	if rs.methodNotAllowed != nil {
+		rs.methodNotAllowed.ServeHTTP(w, req)
+		return
+	}
+	encodeStatus(w, req, http.StatusMethodNotAllowed, think.ErrMethodNotAllowed(req.Method+" "+req.URL.Path))
+}
+
+// encodeStatus writes the think.Envelope of err with another status.
+func encodeStatus(w http.ResponseWriter, req *http.Request, status int, err error) {
+	_, body := think.Envelope(err, req.Header.Get("Accept-Language"))
+	_ = NewHttpContext(w, req).JSON(status, body)
+}
+
+type varsKey struct{}
+
+// Vars returns the path parameters matched by Router for req.
+func Vars(req *http.Request) url.Values {
+	vars, _ := req.Context().Value(varsKey{}).(url.Values)
+	if vars == nil {
+		return url.Values{}
+	}
+	return vars
+}
+
+type node struct {
+	static   map[string]*node
+	param    *node
+	catchAll *node
+	// name of the parameter matched by a param or catchAll node
+	name     string
+	handlers map[string]http.Handler
+}
+
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func (n *node) add(method, path string, segments []string, h http.Handler) {
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if n.param == nil {
+				n.param = &node{name: seg[1:]}
+			} else if n.param.name != seg[1:] {
+				panic(fmt.Sprintf("http: router path %q names :%s where :%s is already used", path, seg[1:], n.param.name))
+			}
+			n = n.param
+		case strings.HasPrefix(seg, "*"):
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("http: router path %q has %s before its end", path, seg))
+			}
+			if n.catchAll == nil {
+				n.catchAll = &node{name: seg[1:]}
+			} else if n.catchAll.name != seg[1:] {
+				panic(fmt.Sprintf("http: router path %q names %s where *%s is already used", path, seg, n.catchAll.name))
+			}
+			n = n.catchAll
+		default:
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			child, ok := n.static[seg]
+			if !ok {
+				child = &node{}
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.Handler)
+	}
+	if _, ok := n.handlers[method]; ok {
+		panic(fmt.Sprintf("http: router path %s %s is registered twice", method, path))
+	}
+	n.handlers[method] = h
+}
+
+// match returns the node of segments with handlers, trying static children
+// first, then the parameter, then the catch-all.
+func (n *node) match(segments []string, vars url.Values) (*node, url.Values) {
+	if len(segments) == 0 {
+		if n.handlers != nil {
+			return n, vars
+		}
+		return nil, nil
+	}
+	seg := segments[0]
+	if child, ok := n.static[seg]; ok {
+		if found, v := child.match(segments[1:], vars); found != nil {
+			return found, v
+		}
+	}
+	if n.param != nil && seg != "" {
+		if found, v := n.param.match(segments[1:], withVar(vars, n.param.name, seg)); found != nil {
+			return found, v
+		}
+	}
+	if n.catchAll != nil && n.catchAll.handlers != nil {
+		return n.catchAll, withVar(vars, n.catchAll.name, strings.Join(segments, "/"))
+	}
+	return nil, nil
+}
+
+// withVar copies vars so a failed branch leaves no parameter behind.
+func withVar(vars url.Values, name, value string) url.Values {
+	out := make(url.Values, len(vars)+1)
+	for k, v := range vars {
+		out[k] = v
+	}
+	out.Set(name, value)
+	return out
+}
+
+func (n *node) allow() string {
+	methods := []string{http.MethodOptions}
+	for m := range n.handlers {
+		if m != http.MethodOptions {
+			methods = append(methods, m)
+		}
+	}
+	if _, ok := n.handlers[http.MethodGet]; ok {
+		if _, ok := n.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/think"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func do(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := NewRouter()
+	route := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(name + " " + Vars(req).Encode()))
+		}
+	}
+	r.Handle(http.MethodGet, "/", route("root"))
+	r.Handle(http.MethodGet, "/users/me", route("me"))
+	r.Handle(http.MethodGet, "/users/:id", route("user"))
+	r.Handle(http.MethodGet, "/users/:id/posts/:post", route("post"))
+	r.Handle(http.MethodGet, "/files/*path", route("file"))
+	api := r.Group("/api/v1/")
+	api.Handle(http.MethodDelete, "/users/:id", route("delete"))
+
+	cases := map[string]string{
+		"GET /":                  "root ",
+		"GET /users/me":          "me ",
+		"GET /users/7":           "user id=7",
+		"GET /users/7/posts/9":   "post id=7&post=9",
+		"GET /files/a/b.txt":     "file path=a%2Fb.txt",
+		"DELETE /api/v1/users/3": "delete id=3",
+		"HEAD /users/7":          "user id=7",
+	}
+	for in, want := range cases {
+		method, path, _ := strings.Cut(in, " ")
+		if got := do(r, method, path).Body.String(); got != want {
+			t.Fatalf("%s: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRouterMisses(t *testing.T) {
+	r := NewRouter()
+	r.HandleFunc(http.MethodGet, "/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+	r.HandleFunc(http.MethodPost, "/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+
+	rec := do(r, http.MethodGet, "/nope")
+	if rec.Code != http.StatusNotFound || !think.IsErrNotFound(DecodeError(rec.Result())) {
+		t.Fatalf("404: %d %s", rec.Code, rec.Body)
+	}
+	rec = do(r, http.MethodPut, "/users/1")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		t.Fatalf("405: %d %s", rec.Code, rec.Header().Get("Allow"))
+	}
+	if err := DecodeError(rec.Result()); !think.IsErrMethodNotAllowed(err) || think.GetCode(err).HttpCode() != http.StatusMethodNotAllowed {
+		t.Fatalf("405 body: %v", err)
+	}
+	rec = do(r, http.MethodOptions, "/users/1")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Allow") == "" {
+		t.Fatalf("OPTIONS: %d", rec.Code)
+	}
+
+	custom := NewRouter(RouterNotFound(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	if do(custom, http.MethodGet, "/").Code != http.StatusTeapot {
+		t.Fatal("RouterNotFound should answer unknown paths")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a path registered twice should panic")
+		}
+	}()
+	r.HandleFunc(http.MethodGet, "/users/:id", func(w http.ResponseWriter, req *http.Request) {})
+}
+
+func TestRouterEndpoint(t *testing.T) {
+	rmc := endpoint.NewRmc()
+	rmc.Endpoint([]endpoint.Protocol{endpoint.Http}, endpoint.MethodGet, "/orders/:id", func(ctx context.Context, request interface{}) (interface{}, error) {
+		c := ctx.(Context)
+		if v, _ := endpoint.GetCtxVal(ctx); v.GetProtocol() != endpoint.Http {
+			t.Error("the endpoint should see its protocol")
+		}
+		return nil, c.String(http.StatusOK, c.Vars().Get("id"))
+	}, nil, nil)
+
+	if _, ok := NewServer(":0").Handler.(*http.ServeMux); !ok || NewServer(":0").Router() != nil {
+		t.Fatal("servers should keep a http.ServeMux unless ServerRouter is set")
+	}
+	srv := NewServer(":0", ServerRouter())
+	rmc.Proxy(srv.Router().Endpoint, endpoint.Http)
+	if got := do(srv.Handler, http.MethodGet, "/orders/42").Body.String(); got != "42" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -4,19 +4,21 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
-	"github.com/zander-84/gull/internal/endpoint"
-	"github.com/zander-84/gull/internal/host"
+	"github.com/zander-84/gull/internal/listen"
 	"github.com/zander-84/gull/transport"
+	"golang.org/x/net/http2"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
 var (
 	_ transport.Server     = (*Server)(nil)
 	_ transport.Endpointer = (*Server)(nil)
+	_ transport.Readier    = (*Server)(nil)
 )
 
 // ServerOption is an HTTP server option.
@@ -29,6 +31,15 @@ func ServerHandler(h http.Handler) ServerOption {
 	}
 }
 
+// ServerRouter serves with the builtin Router instead of a http.ServeMux,
+// register on it through Server.Router.
+func ServerRouter(opts ...RouterOption) ServerOption {
+	return func(s *Server) {
+		s.router = NewRouter(opts...)
+		s.Server.Handler = s.router
+	}
+}
+
 // ServerTLSConfig with server tls config.
 func ServerTLSConfig(c *tls.Config) ServerOption {
 	return func(s *Server) {
@@ -67,6 +78,7 @@ func Listener(lis net.Listener) ServerOption {
 // Server is an HTTP server wrapper.
 type Server struct {
 	*http.Server
+	router       *Router
 	err          error
 	lis          net.Listener
 	endpoint     *url.URL
@@ -76,11 +88,23 @@ type Server struct {
 	readTimeout  time.Duration
 	writeTimeout time.Duration
 	idleTimeout  time.Duration
+	h2c          bool
+	http2        *http2.Server
+	altSvc       string
+	trusted      []*net.IPNet
+	compress     []CompressOption
+	ready        chan struct{}
+	stopping     chan struct{}
+	stopOnce     sync.Once
 }
 
-// NewServer creates a HTTP server by options.
+// NewServer creates a HTTP server by options. Besides host:port, address may
+// be unix:///path/to.sock or systemd:// for a socket passed by systemd, see
+// Endpoint for the url of those.
 func NewServer(address string, opts ...ServerOption) *Server {
 	srv := &Server{
+		ready:        make(chan struct{}),
+		stopping:     make(chan struct{}),
 		network:      "tcp",
 		address:      address,
 		readTimeout:  10 * time.Second,
@@ -94,12 +118,21 @@ func NewServer(address string, opts ...ServerOption) *Server {
 	for _, o := range opts {
 		o(srv)
 	}
+	srv.configure()
 	return srv
 }
 
+// Router returns the router of ServerRouter, nil without it:
+//
+//	srv := http.NewServer(":8000", http.ServerRouter())
+//	rmc.Proxy(srv.Router().Endpoint, endpoint.Http)
+func (s *Server) Router() *Router {
+	return s.router
+}
+
 func (s *Server) listenAndEndpoint() error {
 	if s.lis == nil {
-		lis, err := net.Listen(s.network, s.address)
+		lis, err := listen.Listen(s.network, s.address)
 		if err != nil {
 			s.err = err
 			return err
@@ -107,12 +140,12 @@ func (s *Server) listenAndEndpoint() error {
 		s.lis = lis
 	}
 	if s.endpoint == nil {
-		addr, err := host.Extract(s.address, s.lis)
+		u, err := listen.Endpoint(s.scheme(), s.address, s.lis)
 		if err != nil {
 			s.err = err
 			return err
 		}
-		s.endpoint = endpoint.NewEndpoint(endpoint.Scheme("http", s.tlsConf != nil), addr)
+		s.endpoint = u
 	}
 	return s.err
 }
@@ -121,6 +154,7 @@ func (s *Server) listenAndEndpoint() error {
 // examples:
 //
 //	https://127.0.0.1:8000
+//	http+unix:///run/app.sock
 func (s *Server) Endpoint() (*url.URL, error) {
 	if err := s.listenAndEndpoint(); err != nil {
 		return nil, err
@@ -134,15 +168,18 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 	s.BaseContext = func(net.Listener) context.Context {
-		return ctx
+		// streams watch s.stopping to end on Stop, Shutdown neither cancels
+		// requests nor tracks hijacked connections
+		return context.WithValue(ctx, stoppingKey{}, s.stopping)
 	}
 
 	log.Printf("[HTTP] server listening on: %s", s.lis.Addr().String())
+	lis := listen.Accepting(s.lis, func() { close(s.ready) })
 	var err error
 	if s.tlsConf != nil {
-		err = s.Server.ServeTLS(s.lis, "", "")
+		err = s.Server.ServeTLS(lis, "", "")
 	} else {
-		err = s.Server.Serve(s.lis)
+		err = s.Server.Serve(lis)
 	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -150,8 +187,14 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// Ready is closed once the server accepts connections.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
 // Stop the HTTP server.
 func (s *Server) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stopping) })
 	err := s.Shutdown(ctx)
 	if err == nil {
 		log.Printf("[HTTP]  GracefulStop On: %s\n", s.lis.Addr().String())
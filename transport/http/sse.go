@@ -0,0 +1,196 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrStreamUnsupported is returned when the response writer cannot flush or
+// hijack the connection.
+var ErrStreamUnsupported = errors.New("http: streaming unsupported by the response writer")
+
+// Event is a server-sent event, Data is sent as is when it is a string or
+// []byte, as json otherwise.
+type Event struct {
+	ID    string
+	Event string
+	Data  interface{}
+	// Retry tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// SSEOption is an option of Context.SSE.
+type SSEOption func(*sseOptions)
+
+type sseOptions struct {
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+// SSEHeartbeat sends a comment every d so proxies keep the stream open,
+// 15s by default, 0 turns it off.
+func SSEHeartbeat(d time.Duration) SSEOption {
+	return func(o *sseOptions) {
+		o.heartbeat = d
+	}
+}
+
+// SSERetry sends the reconnection delay of the client first.
+func SSERetry(d time.Duration) SSEOption {
+	return func(o *sseOptions) {
+		o.retry = d
+	}
+}
+
+// EventStream sends the events of Context.SSE.
+type EventStream struct {
+	lock        sync.Mutex
+	w           ResponseWriter
+	lastEventID string
+	done        <-chan struct{}
+}
+
+// LastEventID returns the Last-Event-ID of a reconnecting client, to resume
+// after it.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done is closed when the client goes away or the server stops.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes e and flushes it to the client.
+func (s *EventStream) Send(e Event) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		writeField(&buf, "id", []byte(e.ID))
+	}
+	if e.Event != "" {
+		writeField(&buf, "event", []byte(e.Event))
+	}
+	if e.Retry > 0 {
+		writeField(&buf, "retry", []byte(strconv.FormatInt(e.Retry.Milliseconds(), 10)))
+	}
+	if e.Data != nil {
+		var data []byte
+		switch d := e.Data.(type) {
+		case string:
+			data = []byte(d)
+		case []byte:
+			data = d
+		default:
+			var err error
+			if data, err = json.Marshal(d); err != nil {
+				return err
+			}
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			writeField(&buf, "data", line)
+		}
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+func (s *EventStream) write(p []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-s.done:
+		return context.Canceled
+	default:
+	}
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+func writeField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.Write(bytes.TrimSuffix(value, []byte("\r")))
+	buf.WriteByte('\n')
+}
+
+// SSE answers with a text/event-stream and runs send, which pushes events
+// until it returns or the stream is Done, as when the client goes away or the
+// server stops.
+func (c *wrapper) SSE(send func(s *EventStream) error, opts ...SSEOption) error {
+	o := sseOptions{heartbeat: 15 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, ok := unwrap(c.res).(http.Flusher); !ok {
+		return ErrStreamUnsupported
+	}
+	done, stop := streamDone(c.req.Context())
+	defer stop()
+
+	h := c.res.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	c.res.WriteHeader(http.StatusOK)
+	c.res.Flush()
+
+	s := &EventStream{w: c.res, lastEventID: c.req.Header.Get("Last-Event-ID"), done: done}
+	if o.retry > 0 {
+		if err := s.Send(Event{Retry: o.retry}); err != nil {
+			return err
+		}
+	}
+	if o.heartbeat > 0 {
+		var wg sync.WaitGroup
+		quit := make(chan struct{})
+		defer wg.Wait()
+		defer close(quit)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(o.heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-quit:
+					return
+				case <-done:
+					return
+				case <-ticker.C:
+					_ = s.write([]byte(": ping\n\n"))
+				}
+			}
+		}()
+	}
+	return send(s)
+}
+
+type stoppingKey struct{}
+
+// streamDone returns a channel closed when ctx ends or the server of the
+// request stops, and the func releasing it.
+func streamDone(ctx context.Context) (<-chan struct{}, func()) {
+	stopping, _ := ctx.Value(stoppingKey{}).(chan struct{})
+	if stopping == nil {
+		return ctx.Done(), func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx.Done(), cancel
+}
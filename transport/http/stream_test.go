@@ -0,0 +1,123 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, srv *Server) string {
+	u, err := srv.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Start(context.Background()) }()
+	return u.Host
+}
+
+func stopWithin(t *testing.T, srv *Server, d time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	if err := srv.Stop(ctx); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", ServerRouter())
+	ended := make(chan error, 1)
+	srv.Router().HandleFunc(http.MethodGet, "/events", func(w http.ResponseWriter, req *http.Request) {
+		ended <- NewHttpContext(w, req).SSE(func(s *EventStream) error {
+			from, _ := strconv.Atoi(s.LastEventID())
+			for i := from + 1; i <= from+2; i++ {
+				if err := s.Send(Event{ID: strconv.Itoa(i), Event: "tick", Data: map[string]int{"n": i}}); err != nil {
+					return err
+				}
+			}
+			_ = s.Send(Event{Data: "two\nlines"})
+			<-s.Done()
+			return nil
+		}, SSERetry(time.Second), SSEHeartbeat(10*time.Millisecond))
+	})
+	host := startServer(t, srv)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/events", nil)
+	req.Header.Set("Last-Event-ID", "5")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("content type %s", res.Header.Get("Content-Type"))
+	}
+	want := []string{
+		"retry: 1000", "",
+		"id: 6", "event: tick", `data: {"n":6}`, "",
+		"id: 7", "event: tick", `data: {"n":7}`, "",
+		"data: two", "data: lines", "",
+		": ping", "",
+	}
+	r := bufio.NewReader(res.Body)
+	for _, w := range want {
+		line, err := r.ReadString('\n')
+		if err != nil || strings.TrimSuffix(line, "\n") != w {
+			t.Fatalf("got %q (%v), want %q", line, err, w)
+		}
+	}
+
+	stopWithin(t, srv, time.Second)
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the stream should end on Stop")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", ServerRouter())
+	srv.Router().HandleFunc(http.MethodGet, "/ws", func(w http.ResponseWriter, req *http.Request) {
+		_ = NewHttpContext(w, req).WebSocket(func(ws *websocket.Conn) error {
+			for {
+				var msg string
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					return err
+				}
+				if err := websocket.Message.Send(ws, "echo "+msg); err != nil {
+					return err
+				}
+			}
+		})
+	})
+	host := startServer(t, srv)
+
+	if _, err := websocket.Dial("ws://"+host+"/ws", "", "http://evil.example"); err == nil {
+		t.Fatal("another origin should be refused")
+	}
+	ws, err := websocket.Dial("ws://"+host+"/ws", "", "http://"+host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	var got string
+	if err = websocket.Message.Send(ws, "hi"); err == nil {
+		err = websocket.Message.Receive(ws, &got)
+	}
+	if err != nil || got != "echo hi" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	stopWithin(t, srv, time.Second)
+	_ = ws.SetReadDeadline(time.Now().Add(time.Second))
+	if err = websocket.Message.Receive(ws, &got); err == nil {
+		t.Fatal("the connection should be closed on Stop")
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/zander-84/gull/transport"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCert(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid, tmpl.KeyUsage = true, true, x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	keyDER, _ := x509.MarshalECPrivateKey(c.key)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestMTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "ca", 1, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newCert(t, "server", 2, ca).write(t, dir, "server")
+
+	p, err := transport.NewTLSProvider(certFile, keyFile, transport.WithClientCA(caFile), transport.WithReloadInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	srv := NewServer("127.0.0.1:0", ServerRouter(), ServerTLSConfig(p.Config()))
+	srv.Router().HandleFunc(http.MethodGet, "/whoami", func(w http.ResponseWriter, req *http.Request) {
+		id, _ := transport.PeerIdentityFromContext(req.Context())
+		_, _ = w.Write([]byte(id.CommonName))
+	})
+	u, _ := srv.Endpoint()
+	go func() { _ = srv.Start(context.Background()) }()
+	defer srv.Stop(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(client *testCert) (*http.Response, error) {
+		conf := &tls.Config{RootCAs: roots}
+		if client != nil {
+			conf.Certificates = []tls.Certificate{client.tls()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: conf, ForceAttemptHTTP2: true}}
+		return c.Get(u.String() + "/whoami")
+	}
+
+	res, err := get(newCert(t, "web", 3, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, 16)
+	n, _ := res.Body.Read(body)
+	res.Body.Close()
+	if string(body[:n]) != "web" || res.ProtoMajor != 2 || res.TLS.PeerCertificates[0].SerialNumber.Int64() != 2 {
+		t.Fatalf("got %q over %s", body[:n], res.Proto)
+	}
+	if _, err = get(nil); err == nil {
+		t.Fatal("clients without a certificate should be refused")
+	}
+
+	// rotate the server certificate, a newer mtime triggers the reload
+	newCert(t, "server", 4, ca).write(t, dir, "server")
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	deadline := time.Now().Add(2 * time.Second)
+	serial := func() int64 {
+		leaf, _ := x509.ParseCertificate(p.Certificate().Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+	for serial() != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("the certificate was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"github.com/zander-84/gull/endpoint"
+	"github.com/zander-84/gull/transport"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var _ transport.Transporter = (*wrapper)(nil)
+
+type headerCarrier http.Header
+
+// Get returns the value associated with the passed key.
+func (hc headerCarrier) Get(key string) string {
+	return http.Header(hc).Get(key)
+}
+
+// Set stores the key-value pair.
+func (hc headerCarrier) Set(key string, value string) {
+	http.Header(hc).Set(key, value)
+}
+
+// Keys lists the keys stored in this carrier.
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(hc))
+	for k := range http.Header(hc) {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (c *wrapper) Kind() transport.Kind {
+	return transport.KindHTTP
+}
+
+func (c *wrapper) Endpoint() string {
+	if c.req.TLS != nil {
+		return "https://" + c.req.Host
+	}
+	return "http://" + c.req.Host
+}
+
+// Operation returns the registered route when the request runs inside an endpoint,
+// otherwise the raw request path.
+func (c *wrapper) Operation() string {
+	if v, ok := endpoint.GetCtxVal(c); ok && v.GetPath() != "" {
+		return v.GetPath()
+	}
+	return c.req.URL.Path
+}
+
+func (c *wrapper) RequestHeader() transport.Header {
+	return headerCarrier(c.req.Header)
+}
+
+func (c *wrapper) ReplyHeader() transport.Header {
+	return headerCarrier(c.res.Header())
+}
+
+// RemoteIP returns the client ip, see RemoteIP.
+func (c *wrapper) RemoteIP() string {
+	return RemoteIP(c.req)
+}
+
+// RemoteIP returns the client ip of req. X-Forwarded-For and X-Real-IP are
+// only read on requests from a proxy trusted with ServerTrustedProxies, as
+// any client can forge them, the peer address is used otherwise.
+func RemoteIP(req *http.Request) string {
+	ip := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	trusted, _ := req.Context().Value(trustedKey{}).([]*net.IPNet)
+	if !isTrusted(trusted, ip) {
+		return ip
+	}
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		// each proxy appends the address it got the request from, the client
+		// is the last one not added by a trusted proxy
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			ip = hop
+			if !isTrusted(trusted, hop) {
+				break
+			}
+		}
+		return ip
+	}
+	if xri := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return ip
+}
+
+type trustedKey struct{}
+
+// ServerTrustedProxies makes RemoteIP honor X-Forwarded-For and X-Real-IP on
+// requests from these proxies, given as CIDRs like 10.0.0.0/8 or single ips.
+func ServerTrustedProxies(proxies ...string) ServerOption {
+	return func(s *Server) {
+		s.trusted = s.trusted[:0]
+		for _, p := range proxies {
+			if !strings.Contains(p, "/") {
+				if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+					p += "/32"
+				} else {
+					p += "/128"
+				}
+			}
+			_, n, err := net.ParseCIDR(p)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.trusted = append(s.trusted, n)
+		}
+	}
+}
+
+// trustProxies lets RemoteIP read the forwarding headers set by trusted.
+func trustProxies(next http.Handler, trusted []*net.IPNet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), trustedKey{}, trusted)))
+	})
+}
+
+func isTrusted(trusted []*net.IPNet, ip string) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
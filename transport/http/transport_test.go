@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteIP(t *testing.T) {
+	srv := NewServer(":0", ServerTrustedProxies("10.0.0.0/8", "192.168.1.1"))
+	if srv.err != nil {
+		t.Fatal(srv.err)
+	}
+	var got string
+	h := trustProxies(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = NewHttpContext(w, req).RemoteIP()
+	}), srv.trusted)
+
+	for _, tt := range []struct {
+		peer, xff, xri, want string
+	}{
+		{peer: "203.0.113.9:4000", xff: "1.1.1.1", want: "203.0.113.9"},
+		{peer: "203.0.113.9:4000", xri: "1.1.1.1", want: "203.0.113.9"},
+		{peer: "10.1.2.3:4000", want: "10.1.2.3"},
+		{peer: "10.1.2.3:4000", xff: "1.1.1.1", want: "1.1.1.1"},
+		{peer: "10.1.2.3:4000", xff: "6.6.6.6, 1.1.1.1, 10.0.0.7", want: "1.1.1.1"},
+		{peer: "192.168.1.1:4000", xri: "1.1.1.1", want: "1.1.1.1"},
+		{peer: "192.168.1.2:4000", xri: "1.1.1.1", want: "192.168.1.2"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.peer
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if tt.xri != "" {
+			req.Header.Set("X-Real-IP", tt.xri)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("peer %s xff %q xri %q: got %s, want %s", tt.peer, tt.xff, tt.xri, got, tt.want)
+		}
+	}
+
+	// without trusted proxies the headers are never read
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4000"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+	if ip := RemoteIP(req); ip != "10.1.2.3" {
+		t.Fatalf("untrusted forwarding header used: %s", ip)
+	}
+
+	if srv := NewServer(":0", ServerTrustedProxies("not a cidr")); srv.err == nil {
+		t.Fatal("a bad proxy should fail the server")
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"errors"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/url"
+)
+
+// WebSocket upgrades the request and runs serve with the connection, which
+// is closed when serve returns, the request context ends or the server
+// stops. Browsers from another origin than the request host are refused.
+func (c *wrapper) WebSocket(serve func(ws *websocket.Conn) error) error {
+	if _, ok := unwrap(c.res).(http.Hijacker); !ok {
+		return ErrStreamUnsupported
+	}
+	done, stop := streamDone(c.req.Context())
+	defer stop()
+
+	var err error
+	served := false
+	websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			served = true
+			closed := make(chan struct{})
+			defer close(closed)
+			go func() {
+				select {
+				case <-done:
+					_ = ws.Close()
+				case <-closed:
+				}
+			}()
+			err = serve(ws)
+		},
+	}.ServeHTTP(c.res, c.req)
+	if !served {
+		return errors.New("http: websocket handshake failed")
+	}
+	return err
+}
+
+// sameOrigin accepts requests without Origin, as from non browser clients,
+// or with the Origin of the request host.
+func sameOrigin(conf *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Host != req.Host {
+		return errors.New("http: websocket origin " + origin + " not allowed")
+	}
+	conf.Origin = u
+	return nil
+}
@@ -0,0 +1,247 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOption is a TLSProvider option.
+type TLSOption func(*tlsOptions)
+
+type tlsOptions struct {
+	clientCA   string
+	clientAuth tls.ClientAuthType
+	interval   time.Duration
+	nextProtos []string
+}
+
+// WithClientCA verifies client certificates against the CA bundle of file,
+// reloaded with the certificate, turning on mTLS.
+func WithClientCA(file string) TLSOption {
+	return func(o *tlsOptions) {
+		o.clientCA = file
+	}
+}
+
+// WithClientAuth sets the client certificate policy, RequireAndVerifyClientCert
+// by default with WithClientCA.
+func WithClientAuth(auth tls.ClientAuthType) TLSOption {
+	return func(o *tlsOptions) {
+		o.clientAuth = auth
+	}
+}
+
+// WithReloadInterval sets how often the files are checked for changes,
+// 10s by default, 0 turns the watch off.
+func WithReloadInterval(d time.Duration) TLSOption {
+	return func(o *tlsOptions) {
+		o.interval = d
+	}
+}
+
+// WithNextProtos sets the ALPN protocols offered with WithClientCA,
+// h2 and http/1.1 by default.
+func WithNextProtos(protos ...string) TLSOption {
+	return func(o *tlsOptions) {
+		o.nextProtos = protos
+	}
+}
+
+// TLSProvider serves a certificate and an optional client CA bundle from
+// files, reloading them when they change so they rotate without restart:
+//
+//	p, err := transport.NewTLSProvider("tls.crt", "tls.key", transport.WithClientCA("ca.crt"))
+//	hs := http.NewServer(":8443", http.ServerTLSConfig(p.Config()))
+//	gs := grpc.NewServer(":9443", grpc.TLSConfig(p.Config()))
+//
+// A failed reload keeps the files loaded last.
+type TLSProvider struct {
+	opts     tlsOptions
+	certFile string
+	keyFile  string
+
+	lock    sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewTLSProvider loads the files and starts watching them.
+func NewTLSProvider(certFile, keyFile string, opts ...TLSOption) (*TLSProvider, error) {
+	p := &TLSProvider{
+		opts:     tlsOptions{interval: 10 * time.Second, nextProtos: []string{"h2", "http/1.1"}},
+		certFile: certFile,
+		keyFile:  keyFile,
+		modTime:  make(map[string]time.Time),
+		stop:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(&p.opts)
+	}
+	if p.opts.clientCA != "" && p.opts.clientAuth == tls.NoClientCert {
+		p.opts.clientAuth = tls.RequireAndVerifyClientCert
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	if p.opts.interval > 0 {
+		go p.watch()
+	}
+	return p, nil
+}
+
+// Reload loads the files again.
+func (p *TLSProvider) Reload() error {
+	mod := make(map[string]time.Time, 3)
+	for _, f := range p.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("transport: tls: %w", err)
+		}
+		mod[f] = fi.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return fmt.Errorf("transport: tls: %w", err)
+	}
+	var pool *x509.CertPool
+	if p.opts.clientCA != "" {
+		pem, err := os.ReadFile(p.opts.clientCA)
+		if err != nil {
+			return fmt.Errorf("transport: tls: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("transport: tls: no certificate in %s", p.opts.clientCA)
+		}
+	}
+
+	p.lock.Lock()
+	p.cert, p.pool, p.modTime = &cert, pool, mod
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *TLSProvider) files() []string {
+	files := []string{p.certFile, p.keyFile}
+	if p.opts.clientCA != "" {
+		files = append(files, p.opts.clientCA)
+	}
+	return files
+}
+
+func (p *TLSProvider) changed() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	for _, f := range p.files() {
+		if fi, err := os.Stat(f); err == nil && !fi.ModTime().Equal(p.modTime[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TLSProvider) watch() {
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if !p.changed() {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				log.Printf("[TLS] reload %s: %v", p.certFile, err)
+			} else {
+				log.Printf("[TLS] reloaded %s", p.certFile)
+			}
+		}
+	}
+}
+
+// Close stops watching the files.
+func (p *TLSProvider) Close() error {
+	p.once.Do(func() { close(p.stop) })
+	return nil
+}
+
+// Certificate returns the certificate loaded last.
+func (p *TLSProvider) Certificate() *tls.Certificate {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.cert
+}
+
+// Config returns a server config serving the current files.
+func (p *TLSProvider) Config() *tls.Config {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return p.Certificate(), nil
+		},
+	}
+	if p.opts.clientCA != "" {
+		// a new config per handshake picks up the CA bundle loaded last
+		conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			p.lock.RLock()
+			defer p.lock.RUnlock()
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: conf.GetCertificate,
+				ClientAuth:     p.opts.clientAuth,
+				ClientCAs:      p.pool,
+				NextProtos:     p.opts.nextProtos,
+			}, nil
+		}
+	}
+	return conf
+}
+
+// PeerIdentity is the client certificate of a mTLS connection, verified by
+// the server.
+type PeerIdentity struct {
+	CommonName string
+	DNSNames   []string
+	// URIs holds the URI SANs, like a SPIFFE ID spiffe://cluster.local/ns/default/sa/web
+	URIs        []string
+	Certificate *x509.Certificate
+}
+
+// PeerIdentityFromState returns the identity of the verified client
+// certificate of a connection, if any.
+func PeerIdentityFromState(cs *tls.ConnectionState) (*PeerIdentity, bool) {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	cert := cs.VerifiedChains[0][0]
+	id := &PeerIdentity{CommonName: cert.Subject.CommonName, DNSNames: cert.DNSNames, Certificate: cert}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id, true
+}
+
+type peerIdentityKey struct{}
+
+// NewPeerIdentityContext returns a new Context that carries id, the http and
+// grpc servers set it for mTLS requests.
+func NewPeerIdentityContext(ctx context.Context, id *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, id)
+}
+
+// PeerIdentityFromContext returns the identity of the mTLS client of ctx, if any.
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	id, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return id, ok && id != nil
+}
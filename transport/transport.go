@@ -16,6 +16,13 @@ type Endpointer interface {
 	Endpoint() (*url.URL, error)
 }
 
+// Readier is a Server telling when it accepts connections, the app reports
+// it is ready once all of them do.
+type Readier interface {
+	// Ready is closed once the server accepts connections.
+	Ready() <-chan struct{}
+}
+
 // Header is the storage medium used by a Header.
 type Header interface {
 	Get(key string) string
@@ -68,8 +75,21 @@ func NewServerContext(ctx context.Context, tr Transporter) context.Context {
 	return context.WithValue(ctx, serverTransportKey{}, tr)
 }
 
+// ServerValue answers ctx.Value of a request context that is a Transporter
+// itself, so FromServerContext still finds it once a middleware wraps ctx.
+func ServerValue(tr Transporter, key interface{}) (interface{}, bool) {
+	if _, ok := key.(serverTransportKey); ok {
+		return tr, true
+	}
+	return nil, false
+}
+
 // FromServerContext returns the Transport value stored in ctx, if any.
+// The http and grpc request contexts are Transporters themselves.
 func FromServerContext(ctx context.Context) (tr Transporter, ok bool) {
+	if tr, ok = ctx.(Transporter); ok {
+		return
+	}
 	tr, ok = ctx.Value(serverTransportKey{}).(Transporter)
 	return
 }